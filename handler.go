@@ -3,20 +3,18 @@ package kolibri
 import (
 	"context"
 	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/trace"
 	"golang.org/x/xerrors"
-	corev1 "k8s.io/api/core/v1"
-	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/client-go/informers"
-	"k8s.io/client-go/kubernetes"
-	"k8s.io/client-go/kubernetes/scheme"
-	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
 	"k8s.io/client-go/tools/cache"
-	"k8s.io/client-go/tools/record"
 	"k8s.io/client-go/util/workqueue"
 
 	"github.com/radiofrance/kolibri/kind"
@@ -24,100 +22,173 @@ import (
 )
 
 type Handler struct {
-	ktr    *Kontroller
-	events eventRegistry
-
-	kind     kind.Kind
-	informer kind.Informer
-
-	queue    workqueue.RateLimitingInterface
-	recorder record.EventRecorder
+	ktr  *Kontroller
+	name string
+	// owner is the controller which built the handler, and removed is true
+	// once the handler is unregistered from it (see Register)
+	owner   *Kontroller
+	removed bool
+	events  eventRegistry
+
+	kind      kind.Kind
+	client    interface{}
+	namespace string
+	informer  kind.Informer
+	resync    time.Duration
+	workers   int
+	// timeout is the deadline of each handler function call, if positive
+	timeout      time.Duration
+	timeoutGrace time.Duration
+	predicate    Predicate
+	watches      []kind.Informer
+	debouncer    *debouncer
+	metrics      *handlerMetrics
+
+	// queue only exists while the handler processes events (see process)
+	queueName string
+	queueMu   sync.RWMutex
+	queue     workqueue.RateLimitingInterface
+
+	// inflight contains the events currently handled, with their start time
+	inflightMu sync.Mutex
+	inflight   map[eventContainer]time.Time
+
+	// dropped contains the keys dropped by an operator, with the time they
+	// were dropped (see drop)
+	droppedMu sync.Mutex
+	dropped   map[string]time.Time
+
+	pause pause
+	hooks hooks
+
+	// dependencies are the handlers whose caches must be synced before
+	// processing events
+	dependencies []*Handler
 }
 
 // handlerBuildContext contains all elements used to build an handler.
 // Theses elements are provided by Option interface.
 type handlerBuildContext struct {
-	kind kind.Kind
+	kind      kind.Kind
+	namespace string
+	cluster   string
 
 	informerOpts []informers.SharedInformerOption
 	ktrlOpts     kontrolerOptions
 	eventOpts    eventOptions
+	predicates   []Predicate
+	watches      []watchOption
+	handlerOpts  []handlerOption
+	hooks        hooks
+}
+type Option interface {
+	apply(ctx *handlerBuildContext) error
 }
-type Option interface{ apply(ctx *handlerBuildContext) error }
 
-func (k *Kontroller) NewHandler(opts ...Option) (*Handler, error) {
-	ctx := &handlerBuildContext{}
+// handlerOption wraps functions used to configure the handler itself.
+type handlerOption func(h *Handler) error
 
-	for _, opt := range opts {
-		if err := opt.apply(ctx); err != nil {
-			return nil, err
+func (o handlerOption) apply(ctx *handlerBuildContext) error {
+	ctx.handlerOpts = append(ctx.handlerOpts, o)
+	return nil
+}
+
+// WithName sets the handler name, used to identify it in metrics and logs.
+// By default, the handler is named after its kind.
+func WithName(name string) handlerOption {
+	return func(h *Handler) error {
+		if name == "" {
+			return xerrors.Errorf("handler name cannot be empty")
 		}
+		h.name = name
+		return nil
 	}
+}
 
-	kindOpt := kindOption(nil)
-	factoryOpts := informerFactoryOptions{}
-	ktrlOpts := kontrolerOptions{}
-	eventOpts := eventOptions{}
+// Name returns the handler name.
+func (h *Handler) Name() string { return h.name }
+
+func (k *Kontroller) NewHandler(opts ...Option) (*Handler, error) {
+	ctx := &handlerBuildContext{hooks: hooks{}}
 
 	for _, opt := range opts {
-		switch opt := opt.(type) {
-		case kindOption:
-			if kindOpt != nil {
-				return nil, xerrors.Errorf("only one kind must be provided")
-			}
-			kindOpt = opt
-		case informerFactoryOption:
-			factoryOpts = append(factoryOpts, opt)
-		case kontrolerOption:
-			ktrlOpts = append(ktrlOpts, opt)
-		case eventOption:
-			eventOpts = append(eventOpts, opt)
-		default:
-			return nil, xerrors.Errorf("%T not implemented", opt)
+		if err := opt.apply(ctx); err != nil {
+			return nil, err
 		}
 	}
 
-	if kindOpt == nil {
+	if ctx.kind == nil {
 		return nil, xerrors.Errorf("kind must be provided")
 	}
-	kind, err := kindOpt()
-	if err != nil {
-		return nil, err
-	}
+	kind := ctx.kind
 
-	if len(eventOpts) == 0 {
+	if len(ctx.eventOpts) == 0 {
 		return nil, xerrors.Errorf("at least one event handler (On...) must be provided")
 	}
 
-	handler := &Handler{ktr: k.copy()}
+	owner := k
 	k = k.copy()
+	if err := ctx.ktrlOpts.apply(k); err != nil {
+		return nil, err
+	}
+	name := strings.ToLower(kind.Name())
+	if ctx.cluster != "" {
+		client, err := k.clusters.client(ctx.cluster)
+		if err != nil {
+			return nil, err
+		}
+		k.kube, k.dynamic, k.cluster = client, nil, ctx.cluster
+		k.Logger = k.With(log.String("cluster", ctx.cluster))
+		name += "@" + ctx.cluster
+	}
 	k.Logger = k.Named(fmt.Sprintf("%s/%s", kind.APIVersion(), kind.Name()))
+	handler := &Handler{
+		ktr:       k,
+		owner:     owner,
+		name:      name,
+		kind:      kind,
+		namespace: ctx.namespace,
+		resync:    k.resync,
+		workers:   k.workers,
+		predicate: And(ctx.predicates...),
+		inflight:  map[eventContainer]time.Time{},
+		dropped:   map[string]time.Time{},
+		hooks:     ctx.hooks,
+	}
 
 	client, err := k.client(kind.ClientType())
 	if err != nil {
 		return nil, err
 	}
+	handler.client = client
+	handler.informer = informerKind(kind, ctx.namespace).Informer(client, handler.resync, ctx.informerOpts...)
 
-	informerOpts, err := factoryOpts.apply()
-	if err != nil {
-		return nil, err
+	for _, watch := range ctx.watches {
+		if err := handler.watch(watch, ctx); err != nil {
+			return nil, err
+		}
 	}
-	handler.informer = kind.Informer(client, 5*time.Second, informerOpts...)
 
-	err = ktrlOpts.apply(k)
+	err = ctx.eventOpts.apply(&handler.events)
 	if err != nil {
 		return nil, err
 	}
+	if len(handler.events.finalizers) > 0 && !writable(kind) {
+		return nil, xerrors.Errorf("finalizers cannot be managed on kind %s/%s, which cannot be written", kind.APIVersion(), kind.Name())
+	}
 
-	err = eventOpts.apply(&handler.events)
-	if err != nil {
-		return nil, err
+	handler.queueName = fmt.Sprintf("%s:%s:%s/%s@%s", "kolibris", k.name, kind.APIVersion(), kind.Name(), uuid.New().String())
+
+	for _, opt := range ctx.handlerOpts {
+		if err := opt(handler); err != nil {
+			return nil, err
+		}
 	}
 
-	handler.queue = workqueue.NewNamedRateLimitingQueue(
-		workqueue.DefaultControllerRateLimiter(),
-		fmt.Sprintf("%s:%s:%s/%s@%d", "kolibris", k.name, kind.APIVersion(), kind.Name(), uuid.New().String()),
-	)
+	if k.metrics != nil {
+		handler.metrics = k.metrics.forHandler(k.name, kind.Name(), handler.name)
+		registerQueueMetrics(handler.queueName, handler.metrics)
+	}
 
 	enqueuWith := func(container eventContainer, object metav1.Object) {
 		var key string
@@ -126,37 +197,39 @@ func (k *Kontroller) NewHandler(opts ...Option) (*Handler, error) {
 			return
 		}
 		container.setKey(key)
-		handler.queue.Add(container)
+		handler.enqueue(container)
 	}
 
-	defaultUpdatePolicy := func(old, new metav1.Object) bool { return old.GetResourceVersion() != new.GetResourceVersion() }
-
 	// -- Generic 'add' handler
 	addHandler := func(obj interface{}, kind string) {
-		object, err := baseHandler(handler.ktr.newContext("addHandler"), obj)
-		if err != nil {
+		object, err := baseHandler(handler.ktr.newContext(context.Background(), "addHandler"), obj)
+		if err != nil || !(handler.finalizing(object) || handler.predicate.Create(object)) {
 			return
 		}
 		enqueuWith(&createEvent{baseEvent: &baseEvent{kind: kind}}, object)
 	}
 	// -- Generic 'update' handler
 	updateHandler := func(old, new interface{}, kind string) {
-		oldObject, oerr := baseHandler(handler.ktr.newContext("updateHandler"), old)
-		newObject, nerr := baseHandler(handler.ktr.newContext("updateHandler"), new)
+		oldObject, oerr := baseHandler(handler.ktr.newContext(context.Background(), "updateHandler"), old)
+		newObject, nerr := baseHandler(handler.ktr.newContext(context.Background(), "updateHandler"), new)
 		if oerr != nil || nerr != nil {
 			return
 		}
-		if defaultUpdatePolicy(oldObject, newObject) {
-			enqueuWith(&updateEvent{baseEvent: &baseEvent{kind: kind}}, newObject)
+		// Objects being deleted are finalized regardless of the update policy
+		// and predicates
+		if handler.finalizing(newObject) || handler.ktr.updatePolicy(oldObject, newObject) && handler.predicate.Update(oldObject, newObject) {
+			// Periodic resyncs send unchanged objects
+			resync := oldObject.GetResourceVersion() == newObject.GetResourceVersion()
+			enqueuWith(&updateEvent{baseEvent: &baseEvent{kind: kind, resync: resync}}, newObject)
 		}
 	}
 	// -- Generic 'delete' handler
 	deleteHandler := func(obj interface{}, kind string) {
-		object, err := baseHandler(handler.ktr.newContext("deleteHandler"), obj)
-		if err != nil {
+		object, err := baseHandler(handler.ktr.newContext(context.Background(), "deleteHandler"), obj)
+		if err != nil || !handler.predicate.Delete(object) {
 			return
 		}
-		enqueuWith(&deleteEvent{baseEvent: &baseEvent{kind: kind}}, object)
+		enqueuWith(&deleteEvent{baseEvent: &baseEvent{kind: kind}, obj: object}, object)
 	}
 
 	// -- Add event handler
@@ -166,57 +239,284 @@ func (k *Kontroller) NewHandler(opts ...Option) (*Handler, error) {
 		DeleteFunc: func(obj interface{}) { deleteHandler(obj, kind.Name()) },
 	})
 
-	kk := k.kube.(*kubernetes.Clientset)
-	eventBroadcaster := record.NewBroadcaster()
-	eventBroadcaster.StartLogging(k.Infof)
-	eventBroadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{Interface: kk.CoreV1().Events("")})
-	_ = eventBroadcaster.NewRecorder(scheme.Scheme, corev1.EventSource{Component: "kolibri"})
-
 	return handler, nil
 }
 
-func (h Handler) Run(ctx context.Context) error {
-	defer h.queue.ShutDown()
-	chanStop := make(chan struct{}, 10)
+// Run starts the handler informers and processes their events until the
+// context is done, calling the handler lifecycle hooks (see HookFunc).
+func (h *Handler) Run(ctx context.Context) error {
+	if err := h.hooks.run(h.ktr.newContext(ctx, "hooks"), hookStart); err != nil {
+		return err
+	}
+	if err := h.start(ctx.Done()); err != nil {
+		return err
+	}
+	if err := h.hooks.run(h.ktr.newContext(ctx, "hooks"), hookCacheSynced); err != nil {
+		return err
+	}
+	h.process(ctx)
+	return h.hooks.run(h.ktr.newContext(context.Background(), "hooks"), hookStop)
+}
 
-	h.informer.Start(chanStop)
+// start starts the handler informers and waits until their caches are synced.
+// Events received before the handler processes them are dropped.
+func (h *Handler) start(chanStop <-chan struct{}) error {
+	h.startInformers(chanStop)
+	return h.waitForCacheSync(chanStop)
+}
 
-	if ok := cache.WaitForCacheSync(chanStop, h.informer.HasSynced); !ok {
-		panic("failed to wait for caches to sync")
+// startInformers starts the handler informers.
+func (h *Handler) startInformers(chanStop <-chan struct{}) {
+	h.metrics.setSynced(false)
+	h.informer.Start(chanStop)
+	for _, informer := range h.watches {
+		informer.Start(chanStop)
 	}
+}
 
-	for i := 0; i < 10; i++ {
-		go wait.Until(func() {
-			h.worker()
-		}, time.Second, chanStop)
+// waitForCacheSync waits until the caches of the handler informers are
+// synced.
+func (h *Handler) waitForCacheSync(chanStop <-chan struct{}) error {
+	synced := []cache.InformerSynced{h.informer.HasSynced}
+	for _, informer := range h.watches {
+		synced = append(synced, informer.HasSynced)
 	}
 
-	<-chanStop
+	if ok := cache.WaitForCacheSync(chanStop, synced...); !ok {
+		return xerrors.Errorf("handler '%s' stopped before its caches were synced", h.name)
+	}
+	h.metrics.setSynced(true)
 	return nil
 }
 
+// syncPollPeriod is the period at which caches sync is checked.
+const syncPollPeriod = 100 * time.Millisecond
+
+// synced returns true if the caches of all handler informers are synced.
+func (h *Handler) synced() bool {
+	if !h.informer.HasSynced() {
+		return false
+	}
+	for _, informer := range h.watches {
+		if !informer.HasSynced() {
+			return false
+		}
+	}
+	return true
+}
+
+// process handles the events of a new work queue, starting by all cached
+// objects, until the context is done. In-flight events are then cancelled
+// (through the Kontext) and remaining ones are dropped.
+func (h *Handler) process(ctx context.Context) {
+	if !h.waitForDependencies(ctx) {
+		return
+	}
+
+	h.hooks.runAndLog(h.ktr.newContext(ctx, "hooks"), hookStartedLeading)
+	defer h.hooks.runAndLog(h.ktr.newContext(context.Background(), "hooks"), hookStoppedLeading)
+
+	queue := workqueue.NewNamedRateLimitingQueue(h.ktr.rateLimiter(), h.queueName)
+	h.setQueue(queue)
+	h.resetDropped()
+	h.clearHeld()
+	h.enqueueAll()
+
+	var workers sync.WaitGroup
+	for i := 0; i < h.workers; i++ {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			wait.Until(func() {
+				h.worker(ctx, queue)
+			}, time.Second, ctx.Done())
+		}()
+	}
+
+	for _, sched := range h.events.schedules {
+		// Events of the previous processing have all been dropped
+		atomic.StoreInt32(&sched.pending, 0)
+		go h.runSchedule(ctx.Done(), sched)
+	}
+
+	<-ctx.Done()
+	h.setQueue(nil)
+	queue.ShutDown()
+	workers.Wait()
+}
+
+// enqueueAll enqueues a creation event for each cached object accepted by
+// the handler predicates.
+func (h *Handler) enqueueAll() {
+	objs, err := h.informer.List()
+	if err != nil {
+		h.ktr.With(log.Error("err", err)).Errorf("failed to list cached objects")
+		return
+	}
+
+	for _, obj := range objs {
+		if !(h.finalizing(obj) || h.predicate.Create(obj)) {
+			continue
+		}
+		if key, err := cache.MetaNamespaceKeyFunc(obj); err == nil {
+			h.enqueue(&createEvent{baseEvent: &baseEvent{kind: h.kind.Name(), key: key, initialList: true}})
+		}
+	}
+}
+
 // FIXME clean theses function ... only for testing purpose
 type eventContainer interface {
 	Kind() string
 	Key() string
 	setKey(key string)
+	addedAt() time.Time
+	setAdded(at time.Time)
+	span() trace.Span
+	setSpan(span trace.Span)
+	base() *baseEvent
 }
 type createEvent struct{ *baseEvent }
 type updateEvent struct{ *baseEvent }
-type deleteEvent struct{ *baseEvent }
+type deleteEvent struct {
+	*baseEvent
+	obj metav1.Object
+}
+type replaceEvent struct {
+	*baseEvent
+	deleted metav1.Object
+}
+type genericEvent struct {
+	*baseEvent
+	source kind.Kind
+}
+type scheduledEvent struct {
+	*baseEvent
+	schedule *schedule
+}
+
+// eventType returns the type of the given event.
+func eventType(container eventContainer) EventType {
+	switch container.(type) {
+	case *createEvent:
+		return EventCreate
+	case *updateEvent:
+		return EventUpdate
+	case *deleteEvent:
+		return EventDelete
+	case *replaceEvent:
+		return EventReplace
+	case *genericEvent:
+		return EventGeneric
+	case *scheduledEvent:
+		return EventScheduled
+	}
+	return "unknown"
+}
 
 type baseEvent struct {
-	kind string
-	key  string
+	kind     string
+	key      string
+	added    time.Time
+	rootSpan trace.Span
+
+	// seen is the time the event (or the first of the events coalesced
+	// into it) was received
+	seen        time.Time
+	attempt     int
+	initialList bool
+	resync      bool
+
+	// delayed is the number of retries after a delay, which are not
+	// counted by the queue rate limiter
+	delayed int
 }
 
-func (b baseEvent) Kind() string       { return b.kind }
-func (b baseEvent) Key() string        { return b.key }
-func (b *baseEvent) setKey(key string) { b.key = key }
+func (b baseEvent) Kind() string             { return b.kind }
+func (b baseEvent) Key() string              { return b.key }
+func (b *baseEvent) setKey(key string)       { b.key = key }
+func (b baseEvent) addedAt() time.Time       { return b.added }
+func (b *baseEvent) setAdded(at time.Time)   { b.added = at }
+func (b baseEvent) span() trace.Span         { return b.rootSpan }
+func (b *baseEvent) setSpan(span trace.Span) { b.rootSpan = span }
+func (b *baseEvent) base() *baseEvent        { return b }
+
+// metadata returns the metadata of the given event, as exposed in the
+// Kontext.
+func metadata(container eventContainer) EventMetadata {
+	b := container.base()
+	return EventMetadata{
+		Type:        eventType(container),
+		Key:         b.key,
+		Attempt:     b.attempt,
+		Enqueued:    b.added,
+		FirstSeen:   b.seen,
+		InitialList: b.initialList,
+		Resync:      b.resync,
+	}
+}
 
 // ---------------------------------------------------------------------------------------------------------------//
 // Defines running controller processes
-func (h *Handler) syncHandler(container eventContainer) error {
+
+// workqueue returns the current work queue, nil if the handler does not
+// process events.
+func (h *Handler) workqueue() workqueue.RateLimitingInterface {
+	h.queueMu.RLock()
+	defer h.queueMu.RUnlock()
+	return h.queue
+}
+func (h *Handler) setQueue(queue workqueue.RateLimitingInterface) {
+	h.queueMu.Lock()
+	defer h.queueMu.Unlock()
+	h.queue = queue
+}
+
+// enqueue adds the given event to the work queue, through the debouncer if
+// enabled.
+func (h *Handler) enqueue(container eventContainer) {
+	if b := container.base(); b.seen.IsZero() {
+		b.seen = time.Now()
+	}
+	if h.debouncer != nil {
+		h.debouncer.push(container)
+		return
+	}
+	h.add(container)
+}
+
+// newEventContext returns the Kontext given to the handler functions
+// handling the given event, allowing them to write the handled objects.
+func (h *Handler) newEventContext(ctx context.Context, container eventContainer) *Kontext {
+	ktx := h.ktr.newEventContext(ctx, container)
+	ktx.handler = h
+	return ktx
+}
+
+// add adds the given event to the work queue, returning false if it is
+// dropped because the handler does not process events or because its key
+// belongs to another shard.
+func (h *Handler) add(container eventContainer) bool {
+	if !h.ktr.owns(h.ktr.clusterKey(container.Key())) {
+		return false
+	}
+	queue := h.workqueue()
+	if queue == nil {
+		return false
+	}
+
+	if b := container.base(); b.added.IsZero() {
+		b.added = time.Now()
+		if b.seen.IsZero() {
+			b.seen = b.added
+		}
+	}
+	h.startEventSpan(container)
+	if !h.hold(container) {
+		queue.Add(container)
+	}
+	return true
+}
+func (h *Handler) syncHandler(ctx context.Context, container eventContainer) error {
 	key := container.Key()
 
 	namespace, name, err := cache.SplitMetaNamespaceKey(key)
@@ -224,69 +524,193 @@ func (h *Handler) syncHandler(container eventContainer) error {
 		return err
 	}
 
-	obj, err := h.informer.Get(namespace, name)
-	if err != nil {
-		// The Foo resource may no longer exist, in which case we stop
-		// processing.
-		if errors.IsNotFound(err) {
-			// TODO: Handle error
-			h.ktr.With(log.Error("err", err)).Errorf("service '%s' in work queue no longer exists", key)
-			return nil
+	var obj metav1.Object
+	if deleted, isDeletion := container.(*deleteEvent); isDeletion {
+		// Deleted objects are no longer in the cache
+		obj = deleted.obj
+	} else if obj, err = h.informer.Get(namespace, name); err != nil {
+		// The object may no longer exist, in which case it is ignored (see
+		// classify)
+		return xerrors.Errorf("failed to get '%s' from the cache: %w", key, err)
+	} else if len(h.events.finalizers) > 0 {
+		if obj.GetDeletionTimestamp() != nil {
+			return h.finalize(ctx, container, obj)
+		}
+		if obj, err = h.ensureFinalizers(obj); err != nil {
+			return err
 		}
-		return err
 	}
 
-	var handler handlerFunc
-	switch container.(type) {
+	var handlers []handlerFunc
+	switch container := container.(type) {
 	case *createEvent:
-		handler = handlerFunc(h.events.CreateHandlerFunc)
+		for _, fnc := range h.events.createFuncs {
+			handlers = append(handlers, handlerFunc(fnc))
+		}
 	case *updateEvent:
-		handler = handlerFunc(h.events.UpdateHandlerFunc)
+		for _, fnc := range h.events.updateFuncs {
+			handlers = append(handlers, handlerFunc(fnc))
+		}
 	case *deleteEvent:
-		handler = handlerFunc(h.events.DeleteHandlerFunc)
+		for _, fnc := range h.events.deleteFuncs {
+			handlers = append(handlers, handlerFunc(fnc))
+		}
+	case *genericEvent:
+		for _, fnc := range h.events.genericFuncs {
+			fnc := fnc
+			handlers = append(handlers, func(ktx *Kontext, curr metav1.Object) error { return fnc(ktx, container.source, curr) })
+		}
+		// Without OnGeneric functions, generic events are handled as updates
+		if len(h.events.genericFuncs) == 0 {
+			for _, fnc := range h.events.updateFuncs {
+				handlers = append(handlers, handlerFunc(fnc))
+			}
+		}
+	case *scheduledEvent:
+		handlers = append(handlers, handlerFunc(container.schedule.fnc))
+	case *replaceEvent:
+		for _, fnc := range h.events.deleteFuncs {
+			fnc := fnc
+			handlers = append(handlers, func(ktx *Kontext, _ metav1.Object) error { return fnc(ktx, container.deleted) })
+		}
+		for _, fnc := range h.events.createFuncs {
+			handlers = append(handlers, handlerFunc(fnc))
+		}
 	}
 
-	if handler == nil {
-		return nil
-	}
-	err = handler(h.ktr.newContext(key), obj)
-	if err != nil {
-		return err
+	for i, handler := range handlers {
+		callCtx, call := h.startCallSpan(ctx, container, i)
+		err = h.call(callCtx, container, handler, obj)
+		endSpan(call, err)
+		if err != nil {
+			return err
+		}
 	}
-
-	//eventRecorder.Event(svc, corev1.EventTypeNormal, "Synced", "Service synced successfully")
 	return nil
 }
 
-func (h *Handler) handleErr(err error, key interface{}) {
+func (h *Handler) handleErr(queue workqueue.RateLimitingInterface, err error, key interface{}) {
+	container := key.(eventContainer)
 	if err == nil {
-		h.queue.Forget(key)
+		endEventSpan(container, nil)
+		h.forget(queue, key)
+		return
+	}
+	if after, requeue := requeued(err); requeue {
+		// Scheduled events are handled again on the next tick; requeuing them
+		// would keep their schedule pending, skipping all its next ticks
+		if _, scheduled := key.(*scheduledEvent); scheduled {
+			endEventSpan(container, nil)
+			h.forget(queue, key)
+			return
+		}
+		container.base().delayed = 0
+		queue.Forget(key)
+		queue.AddAfter(key, after)
 		return
 	}
 
-	if h.queue.NumRequeues(key) < 10 {
-		h.queue.AddRateLimited(key)
+	h.ktr.handleError(h.newEventContext(context.Background(), container), err)
+
+	policy, after := classify(err)
+	switch {
+	case policy == retryAfter && h.retries(queue, key) < maxRetries:
+		container.base().delayed++
+		queue.AddAfter(key, after)
+		return
+	case policy == retryRateLimited && h.retries(queue, key) < maxRetries:
+		queue.AddRateLimited(key)
 		return
 	}
 
-	// If number of requeue is above maxRetries, we drop the element out the queue
-	//utilruntime.HandleError(err)
-	h.queue.Forget(key)
+	// Permanent failure or too many retries, the event is dropped
+	h.metrics.droppedEvent(container)
+	endEventSpan(container, xerrors.Errorf("event dropped: %w", err))
+	h.forget(queue, key)
 }
-func (h *Handler) processNextWorkItem() bool {
-	event, shutdown := h.queue.Get()
+
+// retries returns the number of times the given event was retried, either
+// through the queue rate limiter or after a delay.
+func (h *Handler) retries(queue workqueue.RateLimitingInterface, key interface{}) int {
+	return queue.NumRequeues(key) + key.(eventContainer).base().delayed
+}
+
+// forget stops tracking the given event, which will not be retried.
+func (h *Handler) forget(queue workqueue.RateLimitingInterface, key interface{}) {
+	queue.Forget(key)
+	if event, ok := key.(*scheduledEvent); ok {
+		event.schedule.done()
+	}
+}
+func (h *Handler) processNextWorkItem(ctx context.Context, queue workqueue.RateLimitingInterface) bool {
+	event, shutdown := queue.Get()
 	if shutdown {
 		return false
 	}
 
-	defer h.queue.Done(event)
-	err := h.syncHandler(event.(eventContainer))
-	h.handleErr(err, event)
+	defer queue.Done(event)
+	container := event.(eventContainer)
+	if ctx.Err() != nil {
+		// Processing stopped, remaining events are dropped
+		endEventSpan(container, xerrors.New("event dropped: processing stopped"))
+		h.forget(queue, event)
+		return true
+	}
+	if h.isDropped(container) {
+		endEventSpan(container, xerrors.New("event dropped by an operator"))
+		h.forget(queue, event)
+		return true
+	}
+	if h.hold(container) {
+		// Handler paused, the event is replayed on resume
+		queue.Forget(event)
+		return true
+	}
+
+	container.base().attempt = h.retries(queue, event) + 1
+	attemptCtx, attempt := h.startAttemptSpan(ctx, container, container.base().attempt)
+	h.setInflight(container, true)
+	err := h.ignore(container, h.safeSyncHandler(attemptCtx, container))
+	h.setInflight(container, false)
+	result := err
+	if _, requeue := requeued(err); requeue {
+		result = nil
+	}
+	endSpan(attempt, result)
+	h.metrics.handledEvent(container, result)
+	h.handleErr(queue, err, event)
 
 	return true
 }
-func (h *Handler) worker() {
-	for h.processNextWorkItem() {
+
+// setInflight tracks the events currently handled.
+func (h *Handler) setInflight(event eventContainer, inflight bool) {
+	h.inflightMu.Lock()
+	defer h.inflightMu.Unlock()
+
+	if inflight {
+		h.inflight[event] = time.Now()
+	} else {
+		delete(h.inflight, event)
+	}
+}
+
+// oldestInflight returns the event handled for the longest time, if any.
+func (h *Handler) oldestInflight() (eventContainer, time.Time) {
+	h.inflightMu.Lock()
+	defer h.inflightMu.Unlock()
+
+	var oldest eventContainer
+	var since time.Time
+	for event, start := range h.inflight {
+		if oldest == nil || start.Before(since) {
+			oldest, since = event, start
+		}
+	}
+	return oldest, since
+}
+func (h *Handler) worker(ctx context.Context, queue workqueue.RateLimitingInterface) {
+	for h.processNextWorkItem(ctx, queue) {
 	}
 }
 
@@ -301,7 +725,7 @@ func baseHandler(ktx *Kontext, obj interface{}) (metav1.Object, error) {
 		if !ok {
 			return nil, xerrors.Errorf("error decoding object, invalid type")
 		}
-		ktx.Debugf("tombstone found", object.GetName())
+		ktx.Debugf("tombstone found for '%s'", tombstone.Key)
 		object, ok = tombstone.Obj.(metav1.Object)
 		if !ok {
 			return nil, xerrors.Errorf("error decoding object tombstone, invalid type")
@@ -0,0 +1,142 @@
+package kolibri
+
+import (
+	"sync"
+	"time"
+
+	"golang.org/x/xerrors"
+)
+
+// debouncer coalesces all events received for a key until no event has been
+// received for this key during the debounce window.
+type debouncer struct {
+	window time.Duration
+	add    func(container eventContainer) bool
+
+	mu      sync.Mutex
+	pending map[string]*debouncedEvent
+}
+
+type debouncedEvent struct {
+	container eventContainer
+	deadline  time.Time
+}
+
+// WithDebounce delays the events of a key until no other event has been
+// received for this key during the given window. All events of the window
+// are coalesced into a single one:
+//   - create + update  -> create
+//   - any    + delete  -> delete
+//   - delete + create  -> replace (OnDelete with the deleted object, then OnCreate)
+//   - update + update  -> update
+//
+// Generic events are absorbed by any other event. Scheduled events are never
+// delayed.
+func WithDebounce(window time.Duration) handlerOption {
+	return func(h *Handler) error {
+		if window <= 0 {
+			return xerrors.Errorf("debounce window must be positive")
+		}
+		h.debouncer = &debouncer{
+			window:  window,
+			add:     h.add,
+			pending: map[string]*debouncedEvent{},
+		}
+		return nil
+	}
+}
+
+// push merges the given event with the pending one of the same key and
+// resets the key window.
+func (d *debouncer) push(container eventContainer) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	key := container.Key()
+	if pending, exists := d.pending[key]; exists {
+		pending.container = mergeEvents(pending.container, container)
+		pending.deadline = time.Now().Add(d.window)
+		return
+	}
+
+	d.pending[key] = &debouncedEvent{container: container, deadline: time.Now().Add(d.window)}
+	time.AfterFunc(d.window, func() { d.flush(key) })
+}
+
+// flush enqueues the pending event of the given key if its window is over.
+func (d *debouncer) flush(key string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	pending, exists := d.pending[key]
+	if !exists {
+		return
+	}
+	if remaining := time.Until(pending.deadline); remaining > 0 {
+		time.AfterFunc(remaining, func() { d.flush(key) })
+		return
+	}
+
+	delete(d.pending, key)
+	d.add(pending.container)
+}
+
+// mergeEvents coalesces two successive events of the same key. The merged
+// event is first seen when the previous one was, and comes from the initial
+// list or a resync if the previous ones did.
+func mergeEvents(prev, next eventContainer) eventContainer {
+	p, n := prev.base(), next.base()
+	seen := p.seen
+	if seen.IsZero() || (!n.seen.IsZero() && n.seen.Before(seen)) {
+		seen = n.seen
+	}
+	initialList := p.initialList || n.initialList
+	resync := p.resync && n.resync
+
+	merged := coalesceEvents(prev, next)
+	m := merged.base()
+	m.seen, m.initialList, m.resync = seen, initialList, resync
+	return merged
+}
+
+// coalesceEvents returns the event resulting of two successive events of the
+// same key.
+func coalesceEvents(prev, next eventContainer) eventContainer {
+	base := &baseEvent{kind: next.Kind(), key: next.Key()}
+
+	switch next := next.(type) {
+	case *deleteEvent:
+		return next
+	case *createEvent:
+		switch prev := prev.(type) {
+		case *deleteEvent:
+			return &replaceEvent{baseEvent: base, deleted: prev.obj}
+		case *replaceEvent:
+			return &replaceEvent{baseEvent: base, deleted: prev.deleted}
+		}
+		return next
+	case *updateEvent:
+		switch prev := prev.(type) {
+		case *createEvent:
+			return &createEvent{baseEvent: base}
+		case *deleteEvent:
+			return &replaceEvent{baseEvent: base, deleted: prev.obj}
+		case *replaceEvent:
+			return &replaceEvent{baseEvent: base, deleted: prev.deleted}
+		}
+		return next
+	case *genericEvent:
+		if _, isGeneric := prev.(*genericEvent); !isGeneric {
+			return prev
+		}
+		return next
+	}
+	return next
+}
+
+// discard drops the pending event of the given key, if any.
+func (d *debouncer) discard(key string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.pending, key)
+}
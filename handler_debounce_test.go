@@ -0,0 +1,69 @@
+package kolibri
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestMergeEvents(t *testing.T) {
+	deleted := &corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: "deleted"}}
+	create := func() eventContainer { return &createEvent{baseEvent: &baseEvent{key: "k"}} }
+	update := func() eventContainer { return &updateEvent{baseEvent: &baseEvent{key: "k"}} }
+	remove := func() eventContainer { return &deleteEvent{baseEvent: &baseEvent{key: "k"}, obj: deleted} }
+	generic := func() eventContainer { return &genericEvent{baseEvent: &baseEvent{key: "k"}} }
+	replace := func() eventContainer { return &replaceEvent{baseEvent: &baseEvent{key: "k"}, deleted: deleted} }
+
+	tests := []struct {
+		name   string
+		events []eventContainer
+		expect eventContainer
+	}{
+		{"Create+Update", []eventContainer{create(), update()}, create()},
+		{"Update+Update", []eventContainer{update(), update()}, update()},
+		{"Create+Delete", []eventContainer{create(), remove()}, remove()},
+		{"Update+Delete", []eventContainer{update(), remove()}, remove()},
+		{"Delete+Create", []eventContainer{remove(), create()}, replace()},
+		{"Delete+Create+Update", []eventContainer{remove(), create(), update()}, replace()},
+		{"Delete+Create+Delete", []eventContainer{remove(), create(), remove()}, remove()},
+		{"Generic+Update", []eventContainer{generic(), update()}, update()},
+		{"Update+Generic", []eventContainer{update(), generic()}, update()},
+		{"Generic+Generic", []eventContainer{generic(), generic()}, generic()},
+	}
+
+	for _, tt := range tests {
+		merged := tt.events[0]
+		for _, event := range tt.events[1:] {
+			merged = mergeEvents(merged, event)
+		}
+		assert.Equal(t, tt.expect, merged, "Unexpected merged event for %s.", tt.name)
+	}
+}
+
+func TestDebounce(t *testing.T) {
+	handler := newFakeHandler()
+	assert.Error(t, WithDebounce(0)(handler))
+	assert.NoError(t, WithDebounce(300*time.Millisecond)(handler))
+
+	handler.enqueue(&createEvent{baseEvent: &baseEvent{key: "default/a"}})
+	handler.enqueue(&updateEvent{baseEvent: &baseEvent{key: "default/b"}})
+	assert.Equal(t, 0, handler.queue.Len(), "Events must be delayed until their window is over")
+
+	// The default/a window is reset, and ends about 150ms after the default/b one
+	time.Sleep(150 * time.Millisecond)
+	handler.enqueue(&updateEvent{baseEvent: &baseEvent{key: "default/a"}})
+
+	waitFor(t, func() bool { return handler.queue.Len() > 0 }, "default/b window must be over")
+	event, _ := handler.queue.Get()
+	assert.Equal(t, "default/b", event.(eventContainer).Key(), "default/a window must be reset by its second event")
+	handler.queue.Done(event)
+
+	waitFor(t, func() bool { return handler.queue.Len() > 0 }, "default/a window must be over")
+	event, _ = handler.queue.Get()
+	assert.IsType(t, &createEvent{}, event)
+	assert.Equal(t, "default/a", event.(eventContainer).Key())
+	assert.Equal(t, 0, handler.queue.Len(), "Events of a window must be coalesced")
+}
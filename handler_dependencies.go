@@ -0,0 +1,66 @@
+package kolibri
+
+import (
+	"context"
+
+	"golang.org/x/xerrors"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+// DependsOn delays the processing of the handler events until the caches of
+// the given handlers are synced, like for an handler looking up objects of
+// another kind in the cache of its handler.
+//
+// Kontroller.Run already waits for the caches of all registered handlers
+// before processing events; dependencies also apply when handlers are run
+// separately (see Handler.Run), in which case the given handlers must be run
+// too.
+func DependsOn(handlers ...*Handler) handlerOption {
+	return func(h *Handler) error {
+		for _, dependency := range handlers {
+			if dependency == nil {
+				return xerrors.Errorf("handler dependency cannot be nil")
+			}
+			if dependency == h {
+				return xerrors.Errorf("handler cannot depend on itself")
+			}
+			h.dependencies = append(h.dependencies, dependency)
+		}
+		return nil
+	}
+}
+
+// waitForDependencies waits until the caches of the handler dependencies are
+// synced. It returns false if the context is done before.
+func (h *Handler) waitForDependencies(ctx context.Context) bool {
+	for _, dependency := range h.dependencies {
+		if dependency.synced() {
+			continue
+		}
+
+		h.ktr.Infof("handler '%s' waits for the caches of handler '%s'", h.name, dependency.name)
+		err := wait.PollImmediateUntil(syncPollPeriod, func() (bool, error) { return dependency.synced(), nil }, ctx.Done())
+		if err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// checkDependencies returns an error if one of the given handlers depends on
+// an handler which is not part of them.
+func checkDependencies(handlers []*Handler) error {
+	registered := map[*Handler]bool{}
+	for _, handler := range handlers {
+		registered[handler] = true
+	}
+
+	for _, handler := range handlers {
+		for _, dependency := range handler.dependencies {
+			if !registered[dependency] {
+				return xerrors.Errorf("handler '%s' depends on the unregistered handler '%s'", handler.name, dependency.name)
+			}
+		}
+	}
+	return nil
+}
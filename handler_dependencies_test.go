@@ -0,0 +1,67 @@
+package kolibri
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/util/workqueue"
+
+	"github.com/radiofrance/kolibri/kind"
+)
+
+// syncingInformer is a fakeInformer whose cache is synced on demand.
+type syncingInformer struct {
+	fakeInformer
+	synced int32
+}
+
+func (f *syncingInformer) HasSynced() bool { return atomic.LoadInt32(&f.synced) == 1 }
+
+func TestDependsOn(t *testing.T) {
+	informer := &syncingInformer{}
+	dependency := newFakeHandler()
+	dependency.informer = informer
+
+	handler := newFakeHandler()
+	handler.ktr.rateLimiter = workqueue.DefaultControllerRateLimiter
+	handler.setQueue(nil)
+	assert.Error(t, DependsOn(nil)(handler))
+	assert.Error(t, DependsOn(handler)(handler))
+	require.NoError(t, DependsOn(dependency)(handler))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		handler.process(ctx)
+		close(done)
+	}()
+
+	time.Sleep(200 * time.Millisecond)
+	assert.Nil(t, handler.workqueue(), "Handler must wait for its dependencies caches")
+
+	atomic.StoreInt32(&informer.synced, 1)
+	waitFor(t, func() bool { return handler.workqueue() != nil }, "Handler must process events once its dependencies caches are synced")
+
+	cancel()
+	<-done
+}
+
+func TestUnregisteredDependency(t *testing.T) {
+	ktr, err := NewController("test", fake.NewSimpleClientset())
+	require.NoError(t, err)
+
+	onCreate := OnCreate(func(*Kontext, metav1.Object) error { return nil })
+	services, err := ktr.NewHandler(Kind(&kind.Service{}), onCreate)
+	require.NoError(t, err)
+	ingresses, err := ktr.NewHandler(Kind(&kind.Ingress{}), onCreate, DependsOn(services))
+	require.NoError(t, err)
+
+	require.NoError(t, ktr.Register(ingresses))
+	assert.Error(t, ktr.Run(context.Background()))
+}
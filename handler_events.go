@@ -3,20 +3,32 @@ package kolibri
 import (
 	"golang.org/x/xerrors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/radiofrance/kolibri/kind"
 )
 
 // eventRegistry contains user handler function to be called when
 // an action occurs in kubernetes.
 type eventRegistry struct {
-	createFuncs []CreateHandlerFunc
-	updateFuncs []UpdateHandlerFunc
-	deleteFuncs []DeleteHandlerFunc
+	createFuncs  []CreateHandlerFunc
+	updateFuncs  []UpdateHandlerFunc
+	deleteFuncs  []DeleteHandlerFunc
+	genericFuncs []GenericHandlerFunc
+	schedules    []*schedule
+	finalizers   []*finalizer
 }
 
 // eventOption wraps functions defining on to handle kubernetes event on the watched object.
+// Each On... option can be given several times: the functions registered for
+// an event are called in the registration order until one of them fails.
 type eventOption func(events *eventRegistry) error
 type eventOptions []eventOption
 
+func (o eventOption) apply(ctx *handlerBuildContext) error {
+	ctx.eventOpts = append(ctx.eventOpts, o)
+	return nil
+}
+
 func (o eventOptions) apply(events *eventRegistry) error {
 	for _, opt := range o {
 		err := opt(events)
@@ -37,10 +49,10 @@ type CreateHandlerFunc handlerFunc
 // object will be created.
 func OnCreate(fnc CreateHandlerFunc) eventOption {
 	return func(events *eventRegistry) error {
-		if events.CreateHandlerFunc != nil {
-			return xerrors.New("OnCreate can only be called once")
+		if fnc == nil {
+			return xerrors.New("OnCreate handler cannot be nil")
 		}
-		events.CreateHandlerFunc = fnc
+		events.createFuncs = append(events.createFuncs, fnc)
 		return nil
 	}
 }
@@ -52,10 +64,10 @@ type UpdateHandlerFunc handlerFunc
 // object will be updated and validated by the policy.
 func OnChange(fnc UpdateHandlerFunc) eventOption {
 	return func(events *eventRegistry) error {
-		if events.UpdateHandlerFunc != nil {
-			return xerrors.New("OnChange can only be called once")
+		if fnc == nil {
+			return xerrors.New("OnChange handler cannot be nil")
 		}
-		events.UpdateHandlerFunc = fnc
+		events.updateFuncs = append(events.updateFuncs, fnc)
 		return nil
 	}
 }
@@ -67,10 +79,29 @@ type DeleteHandlerFunc handlerFunc
 // object will be removed.
 func OnDelete(fnc DeleteHandlerFunc) eventOption {
 	return func(events *eventRegistry) error {
-		if events.DeleteHandlerFunc != nil {
-			return xerrors.New("OnDelete can only be called once")
+		if fnc == nil {
+			return xerrors.New("OnDelete handler cannot be nil")
+		}
+		events.deleteFuncs = append(events.deleteFuncs, fnc)
+		return nil
+	}
+}
+
+// GenericHandlerFunc is a function that handle events which are neither a
+// creation, an update nor a deletion of the watched object, like changes on
+// other kinds (see Watches). The source is the kind that triggered the
+// event.
+type GenericHandlerFunc func(ktx *Kontext, source kind.Kind, curr metav1.Object) error
+
+// OnGeneric registers function which will be called each time a generic
+// event is triggered for a watched object. Without OnGeneric function,
+// generic events are handled by the OnChange functions.
+func OnGeneric(fnc GenericHandlerFunc) eventOption {
+	return func(events *eventRegistry) error {
+		if fnc == nil {
+			return xerrors.New("OnGeneric handler cannot be nil")
 		}
-		events.DeleteHandlerFunc = fnc
+		events.genericFuncs = append(events.genericFuncs, fnc)
 		return nil
 	}
 }
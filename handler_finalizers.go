@@ -0,0 +1,150 @@
+package kolibri
+
+import (
+	"context"
+
+	"golang.org/x/xerrors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"github.com/radiofrance/kolibri/kind"
+)
+
+// FinalizeHandlerFunc is a function that cleans up what depends on a watched
+// object being deleted, like external resources.
+type FinalizeHandlerFunc handlerFunc
+
+// finalizer is a finalizer managed by the handler.
+type finalizer struct {
+	name string
+	fnc  FinalizeHandlerFunc
+}
+
+// OnFinalize registers function which will be called when a watched object
+// is being deleted, through the given finalizer. The finalizer is added to
+// the watched objects before any other function is called, and removed once
+// the function succeeds; until then, the function is retried through the
+// retry policy and the object deletion is blocked.
+//
+// While an object is being deleted, only the finalize functions are called,
+// regardless of the predicates and update policy. The handler kind must
+// implement kind.Writer.
+func OnFinalize(name string, fnc FinalizeHandlerFunc) eventOption {
+	return func(events *eventRegistry) error {
+		if fnc == nil {
+			return xerrors.New("OnFinalize handler cannot be nil")
+		}
+		if name == "" {
+			return xerrors.New("finalizer name cannot be empty")
+		}
+		for _, finalizer := range events.finalizers {
+			if finalizer.name == name {
+				return xerrors.Errorf("finalizer '%s' is already registered", name)
+			}
+		}
+		events.finalizers = append(events.finalizers, &finalizer{name: name, fnc: fnc})
+		return nil
+	}
+}
+
+// finalizing returns true if the given object is being deleted and has
+// finalizers of the handler.
+func (h *Handler) finalizing(obj metav1.Object) bool {
+	if obj.GetDeletionTimestamp() == nil {
+		return false
+	}
+	for _, finalizer := range h.events.finalizers {
+		if hasFinalizer(obj, finalizer.name) {
+			return true
+		}
+	}
+	return false
+}
+
+// finalize calls the finalize functions of the given object, being deleted,
+// removing each finalizer once its function succeeds.
+func (h *Handler) finalize(ctx context.Context, container eventContainer, obj metav1.Object) error {
+	for i, finalizer := range h.events.finalizers {
+		if !hasFinalizer(obj, finalizer.name) {
+			continue
+		}
+
+		callCtx, call := h.startCallSpan(ctx, container, i)
+		err := h.call(callCtx, container, handlerFunc(finalizer.fnc), obj)
+		endSpan(call, err)
+		if err != nil {
+			return xerrors.Errorf("finalizer '%s' failed: %w", finalizer.name, err)
+		}
+
+		original := obj
+		obj = copyObject(obj)
+		var finalizers []string
+		for _, name := range obj.GetFinalizers() {
+			if name != finalizer.name {
+				finalizers = append(finalizers, name)
+			}
+		}
+		obj.SetFinalizers(finalizers)
+		if obj, err = h.update(original, obj); err != nil {
+			return xerrors.Errorf("failed to remove finalizer '%s': %w", finalizer.name, err)
+		}
+	}
+	return nil
+}
+
+// ensureFinalizers adds the missing finalizers of the handler to the given
+// object, returning the updated object.
+func (h *Handler) ensureFinalizers(obj metav1.Object) (metav1.Object, error) {
+	var missing []string
+	for _, finalizer := range h.events.finalizers {
+		if !hasFinalizer(obj, finalizer.name) {
+			missing = append(missing, finalizer.name)
+		}
+	}
+	if len(missing) == 0 {
+		return obj, nil
+	}
+
+	desired := copyObject(obj)
+	desired.SetFinalizers(append(desired.GetFinalizers(), missing...))
+	updated, err := h.update(obj, desired)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to add finalizers: %w", err)
+	}
+	return updated, nil
+}
+
+// update writes the given modified copy of the original object through the
+// handler kind.
+func (h *Handler) update(original, obj metav1.Object) (metav1.Object, error) {
+	writer, ok := h.kind.(kind.Writer)
+	if !ok {
+		return nil, xerrors.Errorf("kind %s/%s cannot be written", h.kind.APIVersion(), h.kind.Name())
+	}
+	if h.ktr.dryRun {
+		h.ktr.logDryRun("update", original, obj)
+		return obj, nil
+	}
+	return writer.Update(h.client, obj)
+}
+
+// writable returns true if the objects of the given kind can be written.
+func writable(k kind.Kind) bool {
+	_, ok := k.(kind.Writer)
+	return ok
+}
+
+func hasFinalizer(obj metav1.Object, name string) bool {
+	for _, finalizer := range obj.GetFinalizers() {
+		if finalizer == name {
+			return true
+		}
+	}
+	return false
+}
+
+// copyObject returns a deep copy of the given object, which can be modified
+// unlike the cached ones.
+func copyObject(obj metav1.Object) metav1.Object {
+	return obj.(runtime.Object).DeepCopyObject().(metav1.Object)
+}
@@ -0,0 +1,94 @@
+package kolibri
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/xerrors"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestOnFinalize(t *testing.T) {
+	events := &eventRegistry{}
+	fnc := func(*Kontext, metav1.Object) error { return nil }
+
+	assert.NoError(t, OnFinalize("kolibri/a", fnc)(events))
+	assert.NoError(t, OnFinalize("kolibri/b", fnc)(events))
+	assert.Len(t, events.finalizers, 2)
+
+	assert.Error(t, OnFinalize("kolibri/a", fnc)(events), "Finalizers must be unique")
+	assert.Error(t, OnFinalize("", fnc)(events))
+	assert.Error(t, OnFinalize("kolibri/c", nil)(events))
+}
+
+func TestFinalize(t *testing.T) {
+	service := &corev1.Service{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "a", Finalizers: []string{"other"}}}
+	client := fake.NewSimpleClientset(service)
+	get := func() *corev1.Service {
+		svc, err := client.CoreV1().Services("default").Get("a", metav1.GetOptions{})
+		require.NoError(t, err)
+		return svc
+	}
+
+	handler := newFakeHandler(service)
+	handler.client = client
+
+	var updated []string
+	handler.events.updateFuncs = append(handler.events.updateFuncs, func(_ *Kontext, obj metav1.Object) error {
+		updated = append(updated, obj.GetFinalizers()...)
+		return nil
+	})
+	var finalized int
+	var result error
+	require.NoError(t, OnFinalize("kolibri/cleanup", func(*Kontext, metav1.Object) error {
+		finalized++
+		return result
+	})(&handler.events))
+
+	event := func() *updateEvent { return &updateEvent{baseEvent: &baseEvent{key: "default/a"}} }
+
+	// Finalizers are added before calling the other functions
+	require.NoError(t, handler.syncHandler(context.Background(), event()))
+	assert.Equal(t, []string{"other", "kolibri/cleanup"}, get().Finalizers)
+	assert.Equal(t, []string{"other", "kolibri/cleanup"}, updated, "Functions must be given the updated object")
+	assert.Equal(t, []string{"other"}, service.Finalizers, "Cached objects must not be modified")
+	assert.False(t, handler.finalizing(service))
+
+	// Deleted objects are only finalized
+	deleting := get()
+	deleting.DeletionTimestamp = &metav1.Time{}
+	_, err := client.CoreV1().Services("default").Update(deleting)
+	require.NoError(t, err)
+	handler.informer = &fakeInformer{objs: []metav1.Object{deleting}}
+	assert.True(t, handler.finalizing(deleting))
+
+	result = xerrors.New("failure")
+	assert.Error(t, handler.syncHandler(context.Background(), event()))
+	assert.Equal(t, []string{"other", "kolibri/cleanup"}, get().Finalizers, "Finalizers must be kept on failure")
+
+	result = nil
+	require.NoError(t, handler.syncHandler(context.Background(), event()))
+	assert.Equal(t, []string{"other"}, get().Finalizers)
+	assert.Equal(t, 2, finalized)
+	assert.Len(t, updated, 2, "Deleted objects must not be handled by other functions")
+}
+
+func TestFinalizeInitialList(t *testing.T) {
+	deleting := &corev1.Service{ObjectMeta: metav1.ObjectMeta{
+		Namespace:         "default",
+		Name:              "a",
+		Labels:            map[string]string{"skip": "true"},
+		Finalizers:        []string{"kolibri/cleanup"},
+		DeletionTimestamp: &metav1.Time{},
+	}}
+	handler := newFakeHandler(deleting)
+	handler.predicate = ObjectPredicate(func(obj metav1.Object) bool { return obj.GetLabels()["skip"] == "" })
+	require.NoError(t, OnFinalize("kolibri/cleanup", func(*Kontext, metav1.Object) error { return nil })(&handler.events))
+
+	handler.enqueueAll()
+	assert.Equal(t, 1, handler.queue.Len(), "Objects being finalized must be listed regardless of the predicates")
+}
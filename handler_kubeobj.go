@@ -1,18 +1,33 @@
 package kolibri
 
 import (
+	"time"
+
 	"golang.org/x/xerrors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/informers"
 	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/client-go/util/workqueue"
 
 	"github.com/radiofrance/kolibri/kind"
+	"github.com/radiofrance/kolibri/log"
 )
 
 // kindOption wraps a function which verify the validity of a kind.
 type kindOption func() (kind.Kind, error)
 
-func (k kindOption) apply(ctx *handlerBuildContext) error {  }
+func (k kindOption) apply(ctx *handlerBuildContext) error {
+	if ctx.kind != nil {
+		return xerrors.Errorf("only one kind must be provided")
+	}
+
+	kind, err := k()
+	if err != nil {
+		return err
+	}
+	ctx.kind = kind
+	return nil
+}
 
 // Kind register the 'kind' of the kubernetes object which we want to
 // control.
@@ -26,58 +41,56 @@ func Kind(k kind.Kind) kindOption {
 	}
 }
 
-// informerFactoryOption wraps functions used to configure the InformerFactory.
-type informerFactoryOption func() ([]informers.SharedInformerOption, error)
-type informerFactoryOptions []informerFactoryOption
+// informerFactoryOption wraps functions used to configure the InformerFactory,
+// returning the namespace watched by the handler.
+type informerFactoryOption func() (string, error)
 
-func (o informerFactoryOptions) apply() ([]informers.SharedInformerOption, error) {
-	var opts []informers.SharedInformerOption
-
-	for _, factOpt := range o {
-		opt, err := factOpt()
-		if err != nil {
-			return nil, err
-		}
-		opts = append(opts, opt...)
+func (o informerFactoryOption) apply(ctx *handlerBuildContext) error {
+	ns, err := o()
+	if err != nil {
+		return err
 	}
+	ctx.namespace = ns
+	ctx.informerOpts = append(ctx.informerOpts, informers.WithNamespace(ns))
+	return nil
+}
 
-	return opts, nil
+// informerKind returns the given kind, bound to the given namespace if its
+// informers are not configured by the shared informer options.
+func informerKind(k kind.Kind, namespace string) kind.Kind {
+	if namespaced, ok := k.(kind.NamespacedKind); ok {
+		return namespaced.InNamespace(namespace)
+	}
+	return k
 }
 
 // OnAllNamespaces configures the current handler to watch all namespaces (default behavior).
 func OnAllNamespaces() informerFactoryOption {
-	return func() ([]informers.SharedInformerOption, error) {
-		return []informers.SharedInformerOption{
-			informers.WithNamespace(""),
-		}, nil
+	return func() (string, error) {
+		return metav1.NamespaceAll, nil
 	}
 }
 
 // OnNamespace configures the current handler to watch only the specified namespace.
 // Only one can be provided.
 func OnNamespace(ns string) informerFactoryOption {
-	return func() ([]informers.SharedInformerOption, error) {
-		return []informers.SharedInformerOption{
-			informers.WithNamespace(ns),
-		}, nil
+	return func() (string, error) {
+		return ns, nil
 	}
 }
 
 // OnCurrentNamespace configures the current handler to watch the namespace on
 // which the controller runs.
 func OnCurrentNamespace(c clientcmd.ClientConfig) informerFactoryOption {
-	return func() ([]informers.SharedInformerOption, error) {
+	return func() (string, error) {
 		if c == nil {
-			return nil, xerrors.Errorf("client config cannot be nil")
+			return "", xerrors.Errorf("client config cannot be nil")
 		}
 		ns, _, err := c.Namespace()
 		if err != nil {
-			return nil, err
+			return "", err
 		}
-
-		return []informers.SharedInformerOption{
-			informers.WithNamespace(ns),
-		}, nil
+		return ns, nil
 	}
 }
 
@@ -85,9 +98,19 @@ func OnCurrentNamespace(c clientcmd.ClientConfig) informerFactoryOption {
 type UpdateHandlerPolicy func(old, new metav1.Object) bool
 
 // kontrolerOption wraps functions used to configure the handler controller.
+// Given to NewController, they configure the defaults of all handlers.
 type kontrolerOption func(*Kontroller) error
 type kontrolerOptions []kontrolerOption
 
+func (o kontrolerOption) apply(ctx *handlerBuildContext) error {
+	ctx.ktrlOpts = append(ctx.ktrlOpts, o)
+	return nil
+}
+
+// applyController sets the option as a default of all the controller
+// handlers.
+func (o kontrolerOption) applyController(k *Kontroller) error { return o(k) }
+
 func (o kontrolerOptions) apply(k *Kontroller) error {
 	for _, opt := range o {
 		err := opt(k)
@@ -110,3 +133,58 @@ func WithUpdatePolicy(policy UpdateHandlerPolicy) kontrolerOption {
 		return nil
 	}
 }
+
+// WithLogger sets the logger used by the controller and given to the handler
+// functions through the Kontext.
+func WithLogger(logger log.Logger) kontrolerOption {
+	return func(ktr *Kontroller) error {
+		if logger == nil {
+			return xerrors.Errorf("logger cannot be nil")
+		}
+
+		ktr.Logger = logger
+		return nil
+	}
+}
+
+// WithResync sets the period after which informers resend all cached
+// objects (5 seconds by default). Resent objects are only handled if the
+// update policy considers them as updated (see WithUpdatePolicy).
+func WithResync(resync time.Duration) kontrolerOption {
+	return func(ktr *Kontroller) error {
+		if resync < 0 {
+			return xerrors.Errorf("resync period cannot be negative")
+		}
+
+		ktr.resync = resync
+		return nil
+	}
+}
+
+// WithWorkers sets the number of events handled concurrently (10 by
+// default).
+func WithWorkers(workers int) kontrolerOption {
+	return func(ktr *Kontroller) error {
+		if workers <= 0 {
+			return xerrors.Errorf("workers count must be positive")
+		}
+
+		ktr.workers = workers
+		return nil
+	}
+}
+
+// WithRateLimiter sets the function creating the rate limiter of the work
+// queues, delaying the retries of failed events
+// (workqueue.DefaultControllerRateLimiter by default). A new rate limiter is
+// created each time an handler starts processing events.
+func WithRateLimiter(rateLimiter func() workqueue.RateLimiter) kontrolerOption {
+	return func(ktr *Kontroller) error {
+		if rateLimiter == nil {
+			return xerrors.Errorf("rate limiter cannot be nil")
+		}
+
+		ktr.rateLimiter = rateLimiter
+		return nil
+	}
+}
@@ -0,0 +1,144 @@
+package kolibri
+
+import (
+	"sync"
+
+	"golang.org/x/xerrors"
+)
+
+// pause holds the events of a paused handler, coalesced by key.
+type pause struct {
+	mu     sync.Mutex
+	paused bool
+	held   map[string]eventContainer
+	keys   []string // keys of held events, in order of arrival
+}
+
+// Pause stops the handler from handling events, while keeping its caches
+// warm. Events received meanwhile (including those waiting in the queue or
+// being retried) are held and coalesced by key (see WithDebounce) until the
+// handler is resumed. Events currently handled are not cancelled and
+// schedules (see OnSchedule) are skipped.
+func (h *Handler) Pause() {
+	h.pause.mu.Lock()
+	defer h.pause.mu.Unlock()
+
+	if !h.pause.paused {
+		h.pause.paused = true
+		h.ktr.Infof("handler '%s' paused", h.name)
+	}
+}
+
+// Resume replays all events held while the handler was paused, then
+// handles new events as usual.
+func (h *Handler) Resume() {
+	h.pause.mu.Lock()
+	defer h.pause.mu.Unlock()
+
+	if !h.pause.paused {
+		return
+	}
+	h.pause.paused = false
+
+	if queue := h.workqueue(); queue != nil {
+		for _, key := range h.pause.keys {
+			queue.Add(h.pause.held[key])
+		}
+	}
+	h.ktr.Infof("handler '%s' resumed, %d events replayed", h.name, len(h.pause.keys))
+	h.resetHeld()
+}
+
+// Paused returns true if the handler is paused.
+func (h *Handler) Paused() bool {
+	h.pause.mu.Lock()
+	defer h.pause.mu.Unlock()
+	return h.pause.paused
+}
+
+// hold keeps the given event until the handler is resumed, merging it with
+// the held event of the same key. It returns false if the handler is not
+// paused. Scheduled events are not held: their schedule is released and
+// they are discarded.
+func (h *Handler) hold(container eventContainer) bool {
+	h.pause.mu.Lock()
+	defer h.pause.mu.Unlock()
+
+	if !h.pause.paused {
+		return false
+	}
+
+	if event, isScheduled := container.(*scheduledEvent); isScheduled {
+		endEventSpan(event, xerrors.New("scheduled event dropped: handler paused"))
+		event.schedule.done()
+		return true
+	}
+
+	if h.pause.held == nil {
+		h.resetHeld()
+	}
+
+	key := container.Key()
+	if held, exists := h.pause.held[key]; exists {
+		merged := mergeEvents(held, container)
+		if merged.addedAt().IsZero() {
+			merged.setAdded(container.addedAt())
+		}
+		// The merged event keeps the span of the first held event
+		span := held.span()
+		for _, event := range []eventContainer{held, container} {
+			if event.span() != nil && event.span() != span {
+				endEventSpan(event, xerrors.New("event coalesced while handler paused"))
+			}
+		}
+		merged.setSpan(span)
+		h.pause.held[key] = merged
+		return true
+	}
+	h.pause.held[key] = container
+	h.pause.keys = append(h.pause.keys, key)
+	return true
+}
+
+// release discards the held event of the given key, if any.
+func (h *Handler) release(key string) {
+	h.pause.mu.Lock()
+	defer h.pause.mu.Unlock()
+
+	held, exists := h.pause.held[key]
+	if !exists {
+		return
+	}
+	endEventSpan(held, xerrors.New("event dropped by an operator"))
+	delete(h.pause.held, key)
+	for i, k := range h.pause.keys {
+		if k == key {
+			h.pause.keys = append(h.pause.keys[:i], h.pause.keys[i+1:]...)
+			break
+		}
+	}
+}
+
+// heldEvents returns the number of held events.
+func (h *Handler) heldEvents() int {
+	h.pause.mu.Lock()
+	defer h.pause.mu.Unlock()
+	return len(h.pause.keys)
+}
+
+// resetHeld discards all held events. The pause lock must be held.
+func (h *Handler) resetHeld() {
+	h.pause.held = map[string]eventContainer{}
+	h.pause.keys = nil
+}
+
+// clearHeld discards all held events, used when a new queue is created.
+func (h *Handler) clearHeld() {
+	h.pause.mu.Lock()
+	defer h.pause.mu.Unlock()
+
+	for _, held := range h.pause.held {
+		endEventSpan(held, xerrors.New("event dropped: processing stopped"))
+	}
+	h.resetHeld()
+}
@@ -0,0 +1,68 @@
+package kolibri
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestPauseResume(t *testing.T) {
+	handler := newFakeHandler(
+		&corev1.Service{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "a"}},
+		&corev1.Service{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "b"}},
+	)
+	var handled []string
+	handler.events.createFuncs = append(handler.events.createFuncs, func(_ *Kontext, obj metav1.Object) error {
+		handled = append(handled, "create:"+obj.GetName())
+		return nil
+	})
+	handler.events.updateFuncs = append(handler.events.updateFuncs, func(_ *Kontext, obj metav1.Object) error {
+		handled = append(handled, "update:"+obj.GetName())
+		return nil
+	})
+	ctx := context.Background()
+
+	// Events already in the queue are held
+	handler.add(&createEvent{baseEvent: &baseEvent{key: "default/a"}})
+	handler.Pause()
+	assert.True(t, handler.Paused())
+	assert.True(t, handler.processNextWorkItem(ctx, handler.queue))
+
+	// New events are held and coalesced
+	handler.add(&updateEvent{baseEvent: &baseEvent{key: "default/a"}})
+	handler.add(&updateEvent{baseEvent: &baseEvent{key: "default/b"}})
+	handler.add(&updateEvent{baseEvent: &baseEvent{key: "default/c"}})
+	handler.drop("default/c")
+	assert.Equal(t, 0, handler.queue.Len())
+	assert.Equal(t, 2, handler.heldEvents())
+	assert.Empty(t, handled)
+
+	handler.Resume()
+	assert.False(t, handler.Paused())
+	assert.Equal(t, 0, handler.heldEvents())
+	require.Equal(t, 2, handler.queue.Len())
+	assert.True(t, handler.processNextWorkItem(ctx, handler.queue))
+	assert.True(t, handler.processNextWorkItem(ctx, handler.queue))
+	assert.Equal(t, []string{"create:a", "update:b"}, handled)
+}
+
+func TestPauseSchedules(t *testing.T) {
+	handler := newFakeHandler(&corev1.Service{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "a"}})
+	events := &eventRegistry{}
+	require.NoError(t, OnSchedule("@every 5m", func(*Kontext, metav1.Object) error { return nil })(events))
+	sched := events.schedules[0]
+
+	handler.tick(sched)
+	handler.Pause()
+	handler.tick(sched)
+	assert.Equal(t, int32(1), sched.pending)
+
+	// Scheduled events are discarded while paused
+	assert.True(t, handler.processNextWorkItem(context.Background(), handler.queue))
+	assert.Equal(t, int32(0), sched.pending)
+	assert.Equal(t, 0, handler.heldEvents())
+}
@@ -0,0 +1,230 @@
+package kolibri
+
+import (
+	"regexp"
+
+	"golang.org/x/xerrors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+
+	"github.com/radiofrance/kolibri/kind"
+)
+
+// Predicate filters the kubernetes events before they are queued; an event
+// is only queued (and so handled) if the predicate returns true.
+type Predicate interface {
+	// Create returns true if the creation of the given object must be handled.
+	Create(obj metav1.Object) bool
+	// Update returns true if the update of the given object must be handled.
+	Update(old, new metav1.Object) bool
+	// Delete returns true if the deletion of the given object must be handled.
+	Delete(obj metav1.Object) bool
+	// Generic returns true if an event which is not a creation, an update or
+	// a deletion (triggered by another kind, a schedule, ...) must be handled.
+	Generic(obj metav1.Object) bool
+}
+
+// PredicateFuncs implements Predicate through functions. A nil function
+// accepts all events of its type.
+type PredicateFuncs struct {
+	CreateFunc  func(obj metav1.Object) bool
+	UpdateFunc  func(old, new metav1.Object) bool
+	DeleteFunc  func(obj metav1.Object) bool
+	GenericFunc func(obj metav1.Object) bool
+}
+
+func (p PredicateFuncs) Create(obj metav1.Object) bool {
+	return p.CreateFunc == nil || p.CreateFunc(obj)
+}
+func (p PredicateFuncs) Update(old, new metav1.Object) bool {
+	return p.UpdateFunc == nil || p.UpdateFunc(old, new)
+}
+func (p PredicateFuncs) Delete(obj metav1.Object) bool {
+	return p.DeleteFunc == nil || p.DeleteFunc(obj)
+}
+func (p PredicateFuncs) Generic(obj metav1.Object) bool {
+	return p.GenericFunc == nil || p.GenericFunc(obj)
+}
+
+// ObjectPredicate implements Predicate through a single function applied
+// on the object of every event. For updates, only the new object is checked.
+type ObjectPredicate func(obj metav1.Object) bool
+
+func (p ObjectPredicate) Create(obj metav1.Object) bool    { return p(obj) }
+func (p ObjectPredicate) Update(_, new metav1.Object) bool { return p(new) }
+func (p ObjectPredicate) Delete(obj metav1.Object) bool    { return p(obj) }
+func (p ObjectPredicate) Generic(obj metav1.Object) bool   { return p(obj) }
+
+// predicateOption wraps the predicates used to filter events of an handler.
+type predicateOption []Predicate
+
+func (o predicateOption) apply(ctx *handlerBuildContext) error {
+	for _, predicate := range o {
+		if predicate == nil {
+			return xerrors.Errorf("predicate cannot be nil")
+		}
+	}
+	ctx.predicates = append(ctx.predicates, o...)
+	return nil
+}
+
+// WithPredicates filters the events received by the handler; an event is
+// only queued if all predicates accept it. Can be provided several times.
+func WithPredicates(predicates ...Predicate) predicateOption {
+	return predicateOption(predicates)
+}
+
+// ---------------------------------------------------------------------------------------------------------------//
+// Predicate composition
+
+// And returns a predicate accepting an event only if all given predicates
+// accept it.
+func And(predicates ...Predicate) Predicate {
+	return PredicateFuncs{
+		CreateFunc: func(obj metav1.Object) bool {
+			for _, p := range predicates {
+				if !p.Create(obj) {
+					return false
+				}
+			}
+			return true
+		},
+		UpdateFunc: func(old, new metav1.Object) bool {
+			for _, p := range predicates {
+				if !p.Update(old, new) {
+					return false
+				}
+			}
+			return true
+		},
+		DeleteFunc: func(obj metav1.Object) bool {
+			for _, p := range predicates {
+				if !p.Delete(obj) {
+					return false
+				}
+			}
+			return true
+		},
+		GenericFunc: func(obj metav1.Object) bool {
+			for _, p := range predicates {
+				if !p.Generic(obj) {
+					return false
+				}
+			}
+			return true
+		},
+	}
+}
+
+// Or returns a predicate accepting an event if at least one of the given
+// predicates accepts it.
+func Or(predicates ...Predicate) Predicate {
+	return PredicateFuncs{
+		CreateFunc: func(obj metav1.Object) bool {
+			for _, p := range predicates {
+				if p.Create(obj) {
+					return true
+				}
+			}
+			return false
+		},
+		UpdateFunc: func(old, new metav1.Object) bool {
+			for _, p := range predicates {
+				if p.Update(old, new) {
+					return true
+				}
+			}
+			return false
+		},
+		DeleteFunc: func(obj metav1.Object) bool {
+			for _, p := range predicates {
+				if p.Delete(obj) {
+					return true
+				}
+			}
+			return false
+		},
+		GenericFunc: func(obj metav1.Object) bool {
+			for _, p := range predicates {
+				if p.Generic(obj) {
+					return true
+				}
+			}
+			return false
+		},
+	}
+}
+
+// Not returns a predicate accepting an event only if the given predicate
+// rejects it.
+func Not(predicate Predicate) Predicate {
+	return PredicateFuncs{
+		CreateFunc:  func(obj metav1.Object) bool { return !predicate.Create(obj) },
+		UpdateFunc:  func(old, new metav1.Object) bool { return !predicate.Update(old, new) },
+		DeleteFunc:  func(obj metav1.Object) bool { return !predicate.Delete(obj) },
+		GenericFunc: func(obj metav1.Object) bool { return !predicate.Generic(obj) },
+	}
+}
+
+// ---------------------------------------------------------------------------------------------------------------//
+// Stock predicates
+
+// MatchLabels accepts only objects whose labels match the given selector.
+func MatchLabels(selector labels.Selector) Predicate {
+	return ObjectPredicate(func(obj metav1.Object) bool {
+		return selector.Matches(labels.Set(obj.GetLabels()))
+	})
+}
+
+// HasAnnotations accepts only objects having all annotations of the given
+// filter. An empty value in the filter only checks the annotation presence.
+func HasAnnotations(filter AnnotationFilter) Predicate {
+	return ObjectPredicate(func(obj metav1.Object) bool {
+		annotations := obj.GetAnnotations()
+		for key, value := range filter {
+			actual, exists := annotations[key]
+			if !exists || (value != "" && actual != value) {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+// OwnedBy accepts only objects having an owner reference of the given kind.
+func OwnedBy(owner kind.Kind) Predicate {
+	return ObjectPredicate(func(obj metav1.Object) bool {
+		for _, ref := range obj.GetOwnerReferences() {
+			if ref.APIVersion == owner.APIVersion() && ref.Kind == owner.Name() {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// NameMatches accepts only objects whose name matches the given expression.
+func NameMatches(expr *regexp.Regexp) Predicate {
+	return ObjectPredicate(func(obj metav1.Object) bool {
+		return expr.MatchString(obj.GetName())
+	})
+}
+
+// HasFinalizer accepts only objects having the given finalizer.
+func HasFinalizer(finalizer string) Predicate {
+	return ObjectPredicate(func(obj metav1.Object) bool {
+		for _, f := range obj.GetFinalizers() {
+			if f == finalizer {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// IsBeingDeleted accepts only objects whose deletion timestamp is set.
+func IsBeingDeleted() Predicate {
+	return ObjectPredicate(func(obj metav1.Object) bool {
+		return obj.GetDeletionTimestamp() != nil
+	})
+}
@@ -0,0 +1,99 @@
+package kolibri
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+
+	"github.com/radiofrance/kolibri/kind"
+)
+
+func TestStockPredicates(t *testing.T) {
+	now := metav1.Now()
+	obj := &corev1.Service{ObjectMeta: metav1.ObjectMeta{
+		Name:              "svc-frontend",
+		Labels:            map[string]string{"app": "frontend"},
+		Annotations:       map[string]string{"kolibri/enabled": "true"},
+		OwnerReferences:   []metav1.OwnerReference{{APIVersion: "extensions/v1beta1", Kind: "Ingress", Name: "ing"}},
+		Finalizers:        []string{"kolibri/cleanup"},
+		DeletionTimestamp: &now,
+	}}
+	empty := &corev1.Service{}
+
+	tests := []struct {
+		name      string
+		predicate Predicate
+		accepted  bool
+	}{
+		{"MatchLabels", MatchLabels(labels.SelectorFromSet(labels.Set{"app": "frontend"})), true},
+		{"MatchLabels:Mismatch", MatchLabels(labels.SelectorFromSet(labels.Set{"app": "backend"})), false},
+		{"HasAnnotations", HasAnnotations(AnnotationFilter{"kolibri/enabled": "true"}), true},
+		{"HasAnnotations:Presence", HasAnnotations(AnnotationFilter{"kolibri/enabled": ""}), true},
+		{"HasAnnotations:Mismatch", HasAnnotations(AnnotationFilter{"kolibri/enabled": "false"}), false},
+		{"OwnedBy", OwnedBy(&kind.Ingress{}), true},
+		{"OwnedBy:Mismatch", OwnedBy(&kind.Service{}), false},
+		{"NameMatches", NameMatches(regexp.MustCompile("^svc-")), true},
+		{"NameMatches:Mismatch", NameMatches(regexp.MustCompile("^ing-")), false},
+		{"HasFinalizer", HasFinalizer("kolibri/cleanup"), true},
+		{"HasFinalizer:Mismatch", HasFinalizer("kolibri/other"), false},
+		{"IsBeingDeleted", IsBeingDeleted(), true},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.accepted, tt.predicate.Create(obj), "Unexpected creation filtering for %s.", tt.name)
+		assert.Equal(t, tt.accepted, tt.predicate.Update(empty, obj), "Unexpected update filtering for %s.", tt.name)
+		assert.Equal(t, tt.accepted, tt.predicate.Delete(obj), "Unexpected deletion filtering for %s.", tt.name)
+		assert.Equal(t, tt.accepted, tt.predicate.Generic(obj), "Unexpected generic filtering for %s.", tt.name)
+	}
+}
+
+func TestPredicateComposition(t *testing.T) {
+	accept := PredicateFuncs{}
+	reject := Not(accept)
+	onlyCreate := PredicateFuncs{
+		UpdateFunc:  func(_, _ metav1.Object) bool { return false },
+		DeleteFunc:  func(metav1.Object) bool { return false },
+		GenericFunc: func(metav1.Object) bool { return false },
+	}
+	obj := &corev1.Service{}
+
+	tests := []struct {
+		name      string
+		predicate Predicate
+		expect    [4]bool // Create, Update, Delete, Generic
+	}{
+		{"Funcs", accept, [4]bool{true, true, true, true}},
+		{"Not", reject, [4]bool{false, false, false, false}},
+		{"And:Empty", And(), [4]bool{true, true, true, true}},
+		{"And", And(accept, onlyCreate), [4]bool{true, false, false, false}},
+		{"And:Reject", And(accept, reject), [4]bool{false, false, false, false}},
+		{"Or:Empty", Or(), [4]bool{false, false, false, false}},
+		{"Or", Or(reject, onlyCreate), [4]bool{true, false, false, false}},
+		{"Or:Accept", Or(accept, reject), [4]bool{true, true, true, true}},
+		{"Not:Composed", Not(And(accept, onlyCreate)), [4]bool{false, true, true, true}},
+	}
+
+	for _, tt := range tests {
+		actual := [4]bool{
+			tt.predicate.Create(obj),
+			tt.predicate.Update(obj, obj),
+			tt.predicate.Delete(obj),
+			tt.predicate.Generic(obj),
+		}
+		assert.Equal(t, tt.expect, actual, "Unexpected filtering for %s.", tt.name)
+	}
+}
+
+func TestWithPredicates(t *testing.T) {
+	ctx := &handlerBuildContext{}
+
+	assert.NoError(t, WithPredicates(IsBeingDeleted()).apply(ctx))
+	assert.NoError(t, WithPredicates(HasFinalizer("a"), HasFinalizer("b")).apply(ctx))
+	assert.Len(t, ctx.predicates, 3)
+
+	assert.Error(t, WithPredicates(nil).apply(ctx))
+}
@@ -0,0 +1,105 @@
+package kolibri
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	"golang.org/x/xerrors"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/radiofrance/kolibri/log"
+)
+
+// ScheduleHandlerFunc is a function that handle scheduled events.
+type ScheduleHandlerFunc handlerFunc
+
+// schedule contains a function periodically called on all objects of the
+// handler kind.
+type schedule struct {
+	spec     string
+	schedule cron.Schedule
+	fnc      ScheduleHandlerFunc
+	filter   Predicate
+
+	// pending is the number of queued events of the current run.
+	pending int32
+}
+
+// OnSchedule registers function which will be called on each watched object
+// every time the given schedule ticks. The schedule is either a cron spec
+// (`0 * * * *`) or an interval (`@every 5m`). Objects can be filtered by
+// predicates (through their Generic method), in addition to the handler
+// ones. A tick is skipped while the events of the previous one are not all
+// handled, so runs never overlap. Scheduled objects are not requeued by a
+// RequeueAfter without error, the next tick handling them again.
+func OnSchedule(spec string, fnc ScheduleHandlerFunc, filters ...Predicate) eventOption {
+	return func(events *eventRegistry) error {
+		if fnc == nil {
+			return xerrors.New("OnSchedule handler cannot be nil")
+		}
+		sched, err := cron.ParseStandard(spec)
+		if err != nil {
+			return xerrors.Errorf("invalid schedule '%s': %w", spec, err)
+		}
+
+		events.schedules = append(events.schedules, &schedule{
+			spec:     spec,
+			schedule: sched,
+			fnc:      fnc,
+			filter:   And(filters...),
+		})
+		return nil
+	}
+}
+
+// done marks one event of the current run as handled.
+func (s *schedule) done() { atomic.AddInt32(&s.pending, -1) }
+
+// runSchedule ticks the given schedule until the channel is closed.
+func (h *Handler) runSchedule(chanStop <-chan struct{}, s *schedule) {
+	for {
+		timer := time.NewTimer(time.Until(s.schedule.Next(time.Now())))
+		select {
+		case <-chanStop:
+			timer.Stop()
+			return
+		case <-timer.C:
+			h.tick(s)
+		}
+	}
+}
+
+// tick enqueues all cached objects accepted by the schedule filters.
+func (h *Handler) tick(s *schedule) {
+	if h.Paused() {
+		h.ktr.Warnf("schedule '%s' skipped: handler '%s' is paused", s.spec, h.name)
+		return
+	}
+	if pending := atomic.LoadInt32(&s.pending); pending > 0 {
+		h.ktr.Warnf("schedule '%s' skipped: %d events of the previous run are still pending", s.spec, pending)
+		return
+	}
+
+	objs, err := h.informer.List()
+	if err != nil {
+		h.ktr.With(log.Error("err", err)).Errorf("failed to list objects for schedule '%s'", s.spec)
+		return
+	}
+
+	for _, obj := range objs {
+		if !h.predicate.Generic(obj) || !s.filter.Generic(obj) {
+			continue
+		}
+		key, err := cache.MetaNamespaceKeyFunc(obj)
+		if err != nil {
+			continue
+		}
+
+		// Events dropped by add, as for keys of other shards, never complete
+		atomic.AddInt32(&s.pending, 1)
+		if !h.add(&scheduledEvent{baseEvent: &baseEvent{kind: h.kind.Name(), key: key}, schedule: s}) {
+			s.done()
+		}
+	}
+}
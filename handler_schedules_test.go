@@ -0,0 +1,100 @@
+package kolibri
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestOnSchedule(t *testing.T) {
+	events := &eventRegistry{}
+
+	assert.NoError(t, OnSchedule("@every 5m", func(*Kontext, metav1.Object) error { return nil })(events))
+	assert.NoError(t, OnSchedule("0 * * * *", func(*Kontext, metav1.Object) error { return nil })(events))
+	assert.Len(t, events.schedules, 2)
+
+	assert.Error(t, OnSchedule("every 5m", func(*Kontext, metav1.Object) error { return nil })(events))
+	assert.Error(t, OnSchedule("@every 5m", nil)(events))
+}
+
+func TestScheduleTick(t *testing.T) {
+	handler := newFakeHandler(
+		&corev1.Service{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "a", Finalizers: []string{"f"}}},
+		&corev1.Service{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "b"}},
+	)
+	events := &eventRegistry{}
+	assert.NoError(t, OnSchedule("@every 5m", func(*Kontext, metav1.Object) error { return nil }, HasFinalizer("f"))(events))
+	sched := events.schedules[0]
+
+	handler.tick(sched)
+	assert.Equal(t, 1, handler.queue.Len())
+	assert.Equal(t, int32(1), sched.pending)
+
+	// Previous run not finished: nothing is enqueued
+	handler.tick(sched)
+	assert.Equal(t, 1, handler.queue.Len())
+
+	event, _ := handler.queue.Get()
+	assert.IsType(t, &scheduledEvent{}, event)
+	assert.Equal(t, "default/a", event.(eventContainer).Key())
+	assert.NoError(t, handler.syncHandler(context.Background(), event.(eventContainer)))
+	handler.handleErr(handler.queue, nil, event)
+	handler.queue.Done(event)
+	assert.Equal(t, int32(0), sched.pending)
+
+	handler.tick(sched)
+	assert.Equal(t, 1, handler.queue.Len())
+
+	// Requeued events must not block the next ticks
+	event, _ = handler.queue.Get()
+	handler.handleErr(handler.queue, RequeueAfter(nil, time.Millisecond), event)
+	handler.queue.Done(event)
+	assert.Equal(t, int32(0), sched.pending)
+	time.Sleep(10 * time.Millisecond)
+	assert.Zero(t, handler.queue.Len(), "Scheduled events must not be requeued")
+
+	handler.tick(sched)
+	assert.Equal(t, 1, handler.queue.Len())
+}
+
+func TestScheduleTickSharded(t *testing.T) {
+	var objs []metav1.Object
+	for i := 0; i < 20; i++ {
+		objs = append(objs, &corev1.Service{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: fmt.Sprintf("svc-%d", i)}})
+	}
+	handler := newFakeHandler(objs...)
+	handler.ktr.sharding = &sharding{identity: "a"}
+	handler.ktr.sharding.ring.Store(newHashRing([]string{"a", "b"}))
+
+	owned := 0
+	for _, obj := range objs {
+		if handler.ktr.owns(obj.GetNamespace() + "/" + obj.GetName()) {
+			owned++
+		}
+	}
+	require.True(t, owned > 0 && owned < len(objs), "Keys must be spread between both replicas.")
+
+	events := &eventRegistry{}
+	require.NoError(t, OnSchedule("@every 5m", func(*Kontext, metav1.Object) error { return nil })(events))
+	sched := events.schedules[0]
+
+	handler.tick(sched)
+	assert.Equal(t, owned, handler.queue.Len(), "Only the keys of the replica must be enqueued")
+	assert.Equal(t, int32(owned), sched.pending, "Keys of other replicas must not be pending")
+
+	for i := 0; i < owned; i++ {
+		event, _ := handler.queue.Get()
+		handler.handleErr(handler.queue, nil, event)
+		handler.queue.Done(event)
+	}
+	assert.Equal(t, int32(0), sched.pending)
+
+	handler.tick(sched)
+	assert.Equal(t, owned, handler.queue.Len(), "Next runs must not be skipped")
+}
@@ -0,0 +1,103 @@
+package kolibri
+
+import (
+	"context"
+	"time"
+
+	"golang.org/x/xerrors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/radiofrance/kolibri/log"
+)
+
+// ErrHandlerTimeout is returned (wrapped) when an handler function call
+// exceeds its deadline (see WithHandlerTimeout).
+var ErrHandlerTimeout = xerrors.New("handler call timed out")
+
+// WithHandlerTimeout gives each handler function call a deadline, available
+// through the Kontext. A call exceeding its deadline fails and is retried
+// through the retry policy.
+//
+// Handler functions must return once the Kontext is cancelled; calls still
+// running 5 seconds after their deadline or cancellation are reported (logged
+// and counted) and abandoned, releasing their worker, and fail. As abandoned
+// calls keep running, the retry of their event can be handled while they
+// still run: handler functions ignoring cancellation must support concurrent
+// calls for the same object.
+func WithHandlerTimeout(timeout time.Duration) handlerOption {
+	return func(h *Handler) error {
+		if timeout <= 0 {
+			return xerrors.Errorf("handler timeout must be positive")
+		}
+		h.timeout = timeout
+		h.timeoutGrace = 5 * time.Second
+		return nil
+	}
+}
+
+// call calls the given handler function, with a deadline if the handler has
+// a timeout.
+func (h *Handler) call(ctx context.Context, container eventContainer, fnc handlerFunc, obj metav1.Object) error {
+	if h.timeout <= 0 {
+		return fnc(h.newEventContext(ctx, container), obj)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, h.timeout)
+	defer cancel()
+
+	done := make(chan error, 1)
+	panics := make(chan *PanicError, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				panics <- &PanicError{Value: r, Stack: log.Stack("stack").String}
+			}
+		}()
+		done <- fnc(h.newEventContext(ctx, container), obj)
+	}()
+
+	select {
+	case err := <-done:
+		return h.callResult(ctx, container, err)
+	case p := <-panics:
+		// Raised in the worker, to be recovered like other panics
+		panic(p)
+	case <-ctx.Done():
+	}
+
+	select {
+	case err := <-done:
+		return h.callResult(ctx, container, err)
+	case p := <-panics:
+		panic(p)
+	case <-time.After(h.timeoutGrace):
+		reason := "cancellation"
+		if ctx.Err() == context.DeadlineExceeded {
+			reason = "deadline"
+		}
+		h.metrics.ignoredCancellation(container)
+		h.ktr.Warnf("handler '%s' ignores cancellation: call for %s event of '%s' abandoned %s after its %s", h.name, eventType(container), container.Key(), h.timeoutGrace, reason)
+		return h.abandonedCall(ctx)
+	}
+}
+
+// abandonedCall returns the error of an handler function call abandoned
+// after its deadline or the cancellation of its context.
+func (h *Handler) abandonedCall(ctx context.Context) error {
+	if ctx.Err() == context.DeadlineExceeded {
+		return xerrors.Errorf("deadline of %s exceeded, call abandoned: %w", h.timeout, ErrHandlerTimeout)
+	}
+	return xerrors.Errorf("call abandoned after its cancellation: %w", ctx.Err())
+}
+
+// callResult returns the result of an handler function call, which fails if
+// its deadline has been exceeded.
+func (h *Handler) callResult(ctx context.Context, container eventContainer, err error) error {
+	if ctx.Err() != context.DeadlineExceeded {
+		return err
+	}
+	if err != nil {
+		return xerrors.Errorf("deadline of %s exceeded (%v): %w", h.timeout, err, ErrHandlerTimeout)
+	}
+	return xerrors.Errorf("deadline of %s exceeded: %w", h.timeout, ErrHandlerTimeout)
+}
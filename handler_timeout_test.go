@@ -0,0 +1,73 @@
+package kolibri
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/xerrors"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestHandlerTimeout(t *testing.T) {
+	obj := &corev1.Service{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "a"}}
+	event := &updateEvent{baseEvent: &baseEvent{key: "default/a"}}
+
+	handler := newFakeHandler(obj)
+	m, err := newMetrics(prometheus.NewRegistry())
+	require.NoError(t, err)
+	handler.metrics = m.forHandler("test", "Service", "service")
+	assert.Error(t, WithHandlerTimeout(0)(handler))
+	require.NoError(t, WithHandlerTimeout(50*time.Millisecond)(handler))
+	handler.timeoutGrace = 50 * time.Millisecond
+
+	tests := []struct {
+		name    string
+		fnc     handlerFunc
+		timeout bool
+		ignored float64
+	}{
+		{"Fast", func(ktx *Kontext, _ metav1.Object) error {
+			_, hasDeadline := ktx.Deadline()
+			assert.True(t, hasDeadline, "Kontext must have a deadline")
+			return nil
+		}, false, 0},
+		{"Cancelled", func(ktx *Kontext, _ metav1.Object) error {
+			<-ktx.Done()
+			return ktx.Err()
+		}, true, 0},
+		{"Slow", func(*Kontext, metav1.Object) error {
+			time.Sleep(75 * time.Millisecond)
+			return nil
+		}, true, 0},
+		{"IgnoresCancellation", func(*Kontext, metav1.Object) error {
+			time.Sleep(time.Second)
+			return nil
+		}, true, 1},
+	}
+
+	for _, tt := range tests {
+		err := handler.call(context.Background(), event, tt.fnc, obj)
+		assert.Equal(t, tt.timeout, xerrors.Is(err, ErrHandlerTimeout), "Unexpected timeout for %s: %v.", tt.name, err)
+		assert.Equal(t, tt.ignored, testutil.ToFloat64(handler.metrics.ignored.WithLabelValues("update")), "Unexpected ignored cancellations for %s.", tt.name)
+	}
+
+	// Calls ignoring the cancellation of their context fail once abandoned
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+	err = handler.call(ctx, event, tests[3].fnc, obj)
+	assert.True(t, xerrors.Is(err, context.Canceled), "Abandoned calls must fail: %v.", err)
+	assert.False(t, xerrors.Is(err, ErrHandlerTimeout))
+	assert.Equal(t, float64(2), testutil.ToFloat64(handler.metrics.ignored.WithLabelValues("update")))
+
+	// Panics are raised in the worker
+	handler.events.updateFuncs = append(handler.events.updateFuncs, func(*Kontext, metav1.Object) error { panic("boom") })
+	err = handler.safeSyncHandler(context.Background(), event)
+	require.IsType(t, &PanicError{}, err)
+	assert.Contains(t, err.(*PanicError).Stack, "TestHandlerTimeout")
+}
@@ -0,0 +1,146 @@
+package kolibri
+
+import (
+	"context"
+	"strings"
+
+	"golang.org/x/xerrors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/radiofrance/kolibri/kind"
+)
+
+// Key identifies an object of the handler kind.
+type Key struct {
+	// Cluster is the name of the cluster of the object, empty for the
+	// cluster of the client given to NewController (see InCluster).
+	Cluster   string
+	Namespace string
+	Name      string
+}
+
+// String returns the key in the cache.MetaNamespaceKeyFunc format, followed
+// by `@<cluster>` for the objects of named clusters. This format is used by
+// logs, traces and the admin API.
+func (k Key) String() string {
+	key := k.Name
+	if k.Namespace != "" {
+		key = k.Namespace + "/" + k.Name
+	}
+	if k.Cluster != "" {
+		key += "@" + k.Cluster
+	}
+	return key
+}
+
+// parseKey parses a key in the Key.String format.
+func parseKey(s string) (Key, error) {
+	var key Key
+	str := s
+	if i := strings.LastIndex(str, "@"); i >= 0 {
+		str, key.Cluster = str[:i], str[i+1:]
+	}
+
+	var err error
+	key.Namespace, key.Name, err = cache.SplitMetaNamespaceKey(str)
+	if err != nil || key.Name == "" {
+		return Key{}, xerrors.Errorf("invalid key '%s'", s)
+	}
+	return key, nil
+}
+
+// MapFunc maps an object of a watched kind to the keys of the handler kind
+// which must be enqueued. The watched kind is watched in the cluster of the
+// handler, the cluster of the returned keys is ignored.
+type MapFunc func(ktx *Kontext, obj metav1.Object) []Key
+
+// watchOption wraps a secondary kind watched by the handler.
+type watchOption struct {
+	kind   kind.Kind
+	mapper MapFunc
+}
+
+func (o watchOption) apply(ctx *handlerBuildContext) error {
+	if o.kind == nil {
+		return xerrors.Errorf("watched kind cannot be nil")
+	}
+	if o.mapper == nil {
+		return xerrors.Errorf("watched kind mapper cannot be nil")
+	}
+	ctx.watches = append(ctx.watches, o)
+	return nil
+}
+
+// Watches subscribes the handler to the events of another kind. Each event
+// is mapped to keys of the handler kind through the given function; these
+// keys are enqueued in the handler work queue as generic events (see
+// OnGeneric), filtered by the Generic method of the handler predicates.
+// Updates of the watched objects are filtered by the update policy (see
+// WithUpdatePolicy), ignoring periodic resyncs by default.
+func Watches(k kind.Kind, mapper MapFunc) watchOption {
+	return watchOption{kind: k, mapper: mapper}
+}
+
+// EnqueueOwner maps an object to the key of its controlling owner, if this
+// owner is of the given kind.
+func EnqueueOwner(owner kind.Kind) MapFunc {
+	return func(_ *Kontext, obj metav1.Object) []Key {
+		ref := metav1.GetControllerOf(obj)
+		if ref == nil || ref.APIVersion != owner.APIVersion() || ref.Kind != owner.Name() {
+			return nil
+		}
+		return []Key{{Namespace: obj.GetNamespace(), Name: ref.Name}}
+	}
+}
+
+// watch builds the informer of the watched kind and binds its events to
+// the handler work queue.
+func (h *Handler) watch(opt watchOption, ctx *handlerBuildContext) error {
+	client, err := h.ktr.client(opt.kind.ClientType())
+	if err != nil {
+		return err
+	}
+	informer := informerKind(opt.kind, ctx.namespace).Informer(client, h.resync, ctx.informerOpts...)
+
+	// -- Generic secondary handler, enqueuing all mapped keys once
+	mapHandler := func(objs ...interface{}) {
+		ktx := h.ktr.newContext(context.Background(), "watchHandler")
+		keys := map[Key]struct{}{}
+
+		for _, obj := range objs {
+			object, err := baseHandler(ktx, obj)
+			if err != nil {
+				continue
+			}
+			for _, key := range opt.mapper(ktx, object) {
+				key.Cluster = ""
+				keys[key] = struct{}{}
+			}
+		}
+
+		for key := range keys {
+			if obj, err := h.informer.Get(key.Namespace, key.Name); err == nil && !h.predicate.Generic(obj) {
+				continue
+			}
+			h.enqueue(&genericEvent{baseEvent: &baseEvent{kind: h.kind.Name(), key: key.String()}, source: opt.kind})
+		}
+	}
+
+	informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) { mapHandler(obj) },
+		UpdateFunc: func(old, new interface{}) {
+			// Like primary updates, periodic resyncs of unchanged objects are
+			// filtered by the update policy
+			oldObject, ook := old.(metav1.Object)
+			newObject, nok := new.(metav1.Object)
+			if ook && nok && !h.ktr.updatePolicy(oldObject, newObject) {
+				return
+			}
+			mapHandler(old, new)
+		},
+		DeleteFunc: func(obj interface{}) { mapHandler(obj) },
+	})
+	h.watches = append(h.watches, informer)
+	return nil
+}
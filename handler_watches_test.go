@@ -0,0 +1,151 @@
+package kolibri
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	extensionsv1beta1 "k8s.io/api/extensions/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/radiofrance/kolibri/kind"
+)
+
+func TestKeyString(t *testing.T) {
+	assert.Equal(t, "default/svc", Key{Namespace: "default", Name: "svc"}.String())
+	assert.Equal(t, "node", Key{Name: "node"}.String())
+	assert.Equal(t, "default/svc@eu", Key{Cluster: "eu", Namespace: "default", Name: "svc"}.String())
+	assert.Equal(t, "node@eu", Key{Cluster: "eu", Name: "node"}.String())
+}
+
+func TestParseKey(t *testing.T) {
+	for _, key := range []Key{
+		{Namespace: "default", Name: "svc"},
+		{Name: "node"},
+		{Cluster: "eu", Namespace: "default", Name: "svc"},
+		{Cluster: "eu", Name: "node"},
+	} {
+		parsed, err := parseKey(key.String())
+		assert.NoError(t, err)
+		assert.Equal(t, key, parsed)
+	}
+
+	for _, key := range []string{"", "@eu", "default/", "a/b/c"} {
+		_, err := parseKey(key)
+		assert.Error(t, err, "Key '%s' must be invalid.", key)
+	}
+
+	event := EventMetadata{Cluster: "eu", Key: "default/svc"}
+	assert.Equal(t, Key{Cluster: "eu", Namespace: "default", Name: "svc"}, event.ObjectKey())
+}
+
+func TestEnqueueOwner(t *testing.T) {
+	controller := true
+	owned := func(refs ...metav1.OwnerReference) metav1.Object {
+		return &corev1.Service{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "child", OwnerReferences: refs}}
+	}
+
+	tests := []struct {
+		name   string
+		obj    metav1.Object
+		expect []Key
+	}{
+		{"NoOwner", owned(), nil},
+		{"NotController", owned(metav1.OwnerReference{APIVersion: "extensions/v1beta1", Kind: "Ingress", Name: "ing"}), nil},
+		{"OtherKind", owned(metav1.OwnerReference{APIVersion: "v1", Kind: "Service", Name: "svc", Controller: &controller}), nil},
+		{"Controller", owned(metav1.OwnerReference{APIVersion: "extensions/v1beta1", Kind: "Ingress", Name: "ing", Controller: &controller}), []Key{{Namespace: "default", Name: "ing"}}},
+	}
+
+	mapper := EnqueueOwner(&kind.Ingress{})
+	for _, tt := range tests {
+		assert.Equal(t, tt.expect, mapper(nil, tt.obj), "Unexpected keys for %s.", tt.name)
+	}
+}
+
+func TestWatches(t *testing.T) {
+	handler := newFakeHandler(
+		&corev1.Service{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "a"}},
+		&corev1.Service{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "b", Labels: map[string]string{"skip": "true"}}},
+	)
+	handler.predicate = ObjectPredicate(func(obj metav1.Object) bool { return obj.GetLabels()["skip"] == "" })
+
+	// Each ingress is mapped to the services listed in its "services" label
+	mapper := func(_ *Kontext, obj metav1.Object) []Key {
+		var keys []Key
+		for _, name := range strings.Split(obj.GetLabels()["services"], ".") {
+			keys = append(keys, Key{Namespace: obj.GetNamespace(), Name: name})
+		}
+		return keys
+	}
+	source := &watchedKind{informer: &watchedInformer{}}
+	require.NoError(t, handler.watch(Watches(source, mapper), &handlerBuildContext{}))
+	require.Len(t, handler.watches, 1)
+	require.NotNil(t, source.informer.handler)
+
+	ingress := func(services string) *extensionsv1beta1.Ingress {
+		return &extensionsv1beta1.Ingress{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "ing", Labels: map[string]string{"services": services}}}
+	}
+	keys := func() []string {
+		var keys []string
+		for handler.queue.Len() > 0 {
+			event, _ := handler.queue.Get()
+			handler.queue.Done(event)
+			assert.IsType(t, &genericEvent{}, event)
+			keys = append(keys, event.(eventContainer).Key())
+		}
+		sort.Strings(keys)
+		return keys
+	}
+
+	source.informer.handler.OnAdd(ingress("a.a.c"))
+	assert.Equal(t, []string{"default/a", "default/c"}, keys(), "Mapped keys must be enqueued once")
+
+	source.informer.handler.OnAdd(ingress("b"))
+	assert.Empty(t, keys(), "Mapped keys must be filtered by the Generic predicate")
+
+	old, updated := ingress("a"), ingress("a.c")
+	old.ResourceVersion, updated.ResourceVersion = "1", "2"
+	source.informer.handler.OnUpdate(old, updated)
+	assert.Equal(t, []string{"default/a", "default/c"}, keys(), "Keys of both versions must be enqueued once")
+
+	resync := ingress("a")
+	resync.ResourceVersion = "1"
+	source.informer.handler.OnUpdate(resync, resync)
+	assert.Empty(t, keys(), "Resyncs of unchanged objects must not be mapped")
+
+	source.informer.handler.OnDelete(ingress("a"))
+	assert.Equal(t, []string{"default/a"}, keys())
+
+	source.informer.handler.OnDelete(cache.DeletedFinalStateUnknown{Key: "default/ing", Obj: ingress("c")})
+	assert.Equal(t, []string{"default/c"}, keys(), "Deleted objects must be recovered from tombstones")
+}
+
+func TestGenericEvents(t *testing.T) {
+	var changed []string
+	onChange := OnChange(func(_ *Kontext, curr metav1.Object) error {
+		changed = append(changed, curr.GetName())
+		return nil
+	})
+	event := &genericEvent{baseEvent: &baseEvent{key: "default/a"}, source: &kind.Ingress{}}
+
+	// Without OnGeneric functions, generic events are handled as updates
+	handler := newFakeHandler(&corev1.Service{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "a"}})
+	require.NoError(t, onChange(&handler.events))
+	require.NoError(t, handler.syncHandler(context.Background(), event))
+	assert.Equal(t, []string{"a"}, changed)
+
+	var sources []kind.Kind
+	require.NoError(t, OnGeneric(func(_ *Kontext, source kind.Kind, curr metav1.Object) error {
+		sources = append(sources, source)
+		assert.Equal(t, "a", curr.GetName())
+		return nil
+	})(&handler.events))
+	require.NoError(t, handler.syncHandler(context.Background(), event))
+	assert.Equal(t, []kind.Kind{event.source}, sources, "OnGeneric functions must be given the source kind")
+	assert.Equal(t, []string{"a"}, changed, "OnChange functions must not handle generic events with OnGeneric")
+}
@@ -0,0 +1,73 @@
+package kolibri
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+
+	"github.com/radiofrance/kolibri/kind"
+	"github.com/radiofrance/kolibri/log/fake"
+)
+
+// fakeInformer is an in memory kind.Informer.
+type fakeInformer struct{ objs []metav1.Object }
+
+func (f *fakeInformer) AddEventHandler(cache.ResourceEventHandler) {}
+func (f *fakeInformer) Informer() interface{}                      { return nil }
+func (f *fakeInformer) HasSynced() bool                            { return true }
+func (f *fakeInformer) Start(<-chan struct{})                      {}
+func (f *fakeInformer) List() ([]metav1.Object, error)             { return f.objs, nil }
+func (f *fakeInformer) Get(namespace, name string) (metav1.Object, error) {
+	for _, obj := range f.objs {
+		if obj.GetNamespace() == namespace && obj.GetName() == name {
+			return obj, nil
+		}
+	}
+	return nil, errors.NewNotFound(schema.GroupResource{}, name)
+}
+
+func newFakeHandler(objs ...metav1.Object) *Handler {
+	return &Handler{
+		ktr:       &Kontroller{Logger: fake.New()},
+		name:      "service",
+		kind:      &kind.Service{},
+		informer:  &fakeInformer{objs: objs},
+		predicate: And(),
+		queue:     workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter()),
+		workers:   10,
+		inflight:  map[eventContainer]time.Time{},
+		dropped:   map[string]time.Time{},
+	}
+}
+
+// waitFor waits until the given condition is true.
+func waitFor(t *testing.T, condition func() bool, msgAndArgs ...interface{}) {
+	err := wait.PollImmediate(10*time.Millisecond, 5*time.Second, func() (bool, error) { return condition(), nil })
+	require.NoError(t, err, msgAndArgs...)
+}
+
+// watchedKind is a kind whose informer records its event handler, allowing
+// tests to feed events through it.
+type watchedKind struct {
+	kind.Ingress
+	informer *watchedInformer
+}
+
+type watchedInformer struct {
+	fakeInformer
+	handler cache.ResourceEventHandler
+}
+
+func (w *watchedInformer) AddEventHandler(handler cache.ResourceEventHandler) { w.handler = handler }
+
+func (k *watchedKind) Informer(interface{}, time.Duration, ...informers.SharedInformerOption) kind.Informer {
+	return k.informer
+}
@@ -1,9 +1,13 @@
 package kind
 
 import (
+	"reflect"
 	"time"
 
+	apicorev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/informers"
 	corev1 "k8s.io/client-go/informers/core/v1"
 	"k8s.io/client-go/kubernetes"
@@ -25,11 +29,44 @@ func (Service) Informer(client interface{}, resync time.Duration, options ...inf
 	factory := informers.NewSharedInformerFactoryWithOptions(client.(kubernetes.Interface), resync, options...)
 	return &ServiceInformer{informer: factory.Core().V1().Services(), factory: factory}
 }
+func (Service) Get(client interface{}, namespace, name string) (metav1.Object, error) {
+	return client.(kubernetes.Interface).CoreV1().Services(namespace).Get(name, metav1.GetOptions{})
+}
+func (Service) Update(client interface{}, obj metav1.Object) (metav1.Object, error) {
+	return client.(kubernetes.Interface).CoreV1().Services(obj.GetNamespace()).Update(obj.(*apicorev1.Service))
+}
+func (Service) UpdateStatus(client interface{}, obj metav1.Object) (metav1.Object, error) {
+	return client.(kubernetes.Interface).CoreV1().Services(obj.GetNamespace()).UpdateStatus(obj.(*apicorev1.Service))
+}
+func (Service) Patch(client interface{}, namespace, name string, pt types.PatchType, data []byte, options metav1.PatchOptions) (metav1.Object, error) {
+	kube := client.(kubernetes.Interface).CoreV1()
+	if reflect.DeepEqual(options, metav1.PatchOptions{}) {
+		return kube.Services(namespace).Patch(name, pt, data)
+	}
+
+	result := &apicorev1.Service{}
+	if err := restPatch(kube.RESTClient(), "services", namespace, name, pt, data, options, result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
 func (i ServiceInformer) Informer() interface{} { return i.informer }
 func (i ServiceInformer) HasSynced() bool       { return i.informer.Informer().HasSynced() }
 func (i ServiceInformer) Get(namespace, name string) (metav1.Object, error) {
 	return i.informer.Lister().Services(namespace).Get(name)
 }
+func (i ServiceInformer) List() ([]metav1.Object, error) {
+	svcs, err := i.informer.Lister().List(labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+
+	objs := make([]metav1.Object, len(svcs))
+	for idx, obj := range svcs {
+		objs[idx] = obj
+	}
+	return objs, nil
+}
 func (i ServiceInformer) AddEventHandler(handler cache.ResourceEventHandler) {
 	i.informer.Informer().AddEventHandler(handler)
 }
@@ -1,9 +1,13 @@
 package kind
 
 import (
+	"reflect"
 	"time"
 
+	apiextensionsv1beta1 "k8s.io/api/extensions/v1beta1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/informers"
 	"k8s.io/client-go/informers/extensions/v1beta1"
 	"k8s.io/client-go/kubernetes"
@@ -22,11 +26,44 @@ func (Ingress) Informer(client interface{}, resync time.Duration, options ...inf
 	factory := informers.NewSharedInformerFactoryWithOptions(client.(kubernetes.Interface), resync, options...)
 	return &IngressInformer{informer: factory.Extensions().V1beta1().Ingresses(), factory: factory}
 }
+func (Ingress) Get(client interface{}, namespace, name string) (metav1.Object, error) {
+	return client.(kubernetes.Interface).ExtensionsV1beta1().Ingresses(namespace).Get(name, metav1.GetOptions{})
+}
+func (Ingress) Update(client interface{}, obj metav1.Object) (metav1.Object, error) {
+	return client.(kubernetes.Interface).ExtensionsV1beta1().Ingresses(obj.GetNamespace()).Update(obj.(*apiextensionsv1beta1.Ingress))
+}
+func (Ingress) UpdateStatus(client interface{}, obj metav1.Object) (metav1.Object, error) {
+	return client.(kubernetes.Interface).ExtensionsV1beta1().Ingresses(obj.GetNamespace()).UpdateStatus(obj.(*apiextensionsv1beta1.Ingress))
+}
+func (Ingress) Patch(client interface{}, namespace, name string, pt types.PatchType, data []byte, options metav1.PatchOptions) (metav1.Object, error) {
+	kube := client.(kubernetes.Interface).ExtensionsV1beta1()
+	if reflect.DeepEqual(options, metav1.PatchOptions{}) {
+		return kube.Ingresses(namespace).Patch(name, pt, data)
+	}
+
+	result := &apiextensionsv1beta1.Ingress{}
+	if err := restPatch(kube.RESTClient(), "ingresses", namespace, name, pt, data, options, result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
 func (i IngressInformer) Informer() interface{} { return i.informer }
 func (i IngressInformer) HasSynced() bool       { return i.informer.Informer().HasSynced() }
 func (i IngressInformer) Get(namespace, name string) (metav1.Object, error) {
 	return i.informer.Lister().Ingresses(namespace).Get(name)
 }
+func (i IngressInformer) List() ([]metav1.Object, error) {
+	ings, err := i.informer.Lister().List(labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+
+	objs := make([]metav1.Object, len(ings))
+	for idx, obj := range ings {
+		objs[idx] = obj
+	}
+	return objs, nil
+}
 func (i IngressInformer) AddEventHandler(handler cache.ResourceEventHandler) {
 	i.informer.Informer().AddEventHandler(handler)
 }
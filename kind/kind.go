@@ -5,6 +5,7 @@ import (
 	"time"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/informers"
 	"k8s.io/client-go/tools/cache"
 )
@@ -24,6 +25,24 @@ type Informer interface {
 	HasSynced() bool
 
 	Get(namespace, name string) (metav1.Object, error)
+	List() ([]metav1.Object, error)
 
 	Start(<-chan struct{})
 }
+
+// NamespacedKind is implemented by kinds whose informers are not configured
+// by the shared informer options; they are given the namespace watched by
+// the handler, empty for all namespaces.
+type NamespacedKind interface {
+	InNamespace(namespace string) Kind
+}
+
+// Writer is implemented by kinds whose objects can be written by the
+// controller (see kolibri.OnFinalize or kolibri.Kontext.UpdateStatus). Get
+// reads the object from the API server, bypassing the informers caches.
+type Writer interface {
+	Get(client interface{}, namespace, name string) (metav1.Object, error)
+	Update(client interface{}, obj metav1.Object) (metav1.Object, error)
+	UpdateStatus(client interface{}, obj metav1.Object) (metav1.Object, error)
+	Patch(client interface{}, namespace, name string, pt types.PatchType, data []byte, options metav1.PatchOptions) (metav1.Object, error)
+}
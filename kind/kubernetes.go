@@ -3,9 +3,28 @@ package kind
 import (
 	"reflect"
 
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
 )
 
 type kubeKind struct{}
 
 func (kubeKind) ClientType() reflect.Type { return reflect.TypeOf(kubernetes.Interface(nil)) }
+
+// restPatch patches an object through a REST client, as the typed clients do
+// not support patch options (like the field manager required by server-side
+// apply).
+func restPatch(client rest.Interface, resource, namespace, name string, pt types.PatchType, data []byte, options metav1.PatchOptions, result runtime.Object) error {
+	return client.Patch(pt).
+		Namespace(namespace).
+		Resource(resource).
+		Name(name).
+		VersionedParams(&options, scheme.ParameterCodec).
+		Body(data).
+		Do().
+		Into(result)
+}
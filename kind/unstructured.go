@@ -0,0 +1,97 @@
+package kind
+
+import (
+	"reflect"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/tools/cache"
+)
+
+// Unstructured is a kind handled through the dynamic client, its objects
+// being *unstructured.Unstructured; it allows handling kinds without typed
+// client, like custom resources.
+type Unstructured struct {
+	// Resource is the API resource of the kind, like
+	// `{Group: "example.com", Version: "v1", Resource: "foos"}`
+	Resource schema.GroupVersionResource
+	// Kind is the name of the kind, like `Foo`
+	Kind string
+
+	// namespace is the namespace watched by the informers, all if empty
+	namespace string
+}
+type UnstructuredInformer struct {
+	informer informers.GenericInformer
+}
+
+func (Unstructured) ClientType() reflect.Type {
+	return reflect.TypeOf((*dynamic.Interface)(nil)).Elem()
+}
+func (u Unstructured) APIVersion() string { return u.Resource.GroupVersion().String() }
+func (u Unstructured) Name() string       { return u.Kind }
+
+// InNamespace implements NamespacedKind, as the dynamic informers are not
+// built through a shared informer factory.
+func (u Unstructured) InNamespace(namespace string) Kind {
+	u.namespace = namespace
+	return &u
+}
+func (u Unstructured) Informer(client interface{}, resync time.Duration, _ ...informers.SharedInformerOption) Informer {
+	indexers := cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc}
+	informer := dynamicinformer.NewFilteredDynamicInformer(client.(dynamic.Interface), u.Resource, u.namespace, resync, indexers, nil)
+	return &UnstructuredInformer{informer: informer}
+}
+func (u Unstructured) Get(client interface{}, namespace, name string) (metav1.Object, error) {
+	return client.(dynamic.Interface).Resource(u.Resource).Namespace(namespace).Get(name, metav1.GetOptions{})
+}
+func (u Unstructured) Update(client interface{}, obj metav1.Object) (metav1.Object, error) {
+	return client.(dynamic.Interface).Resource(u.Resource).Namespace(obj.GetNamespace()).Update(obj.(*unstructured.Unstructured), metav1.UpdateOptions{})
+}
+func (u Unstructured) UpdateStatus(client interface{}, obj metav1.Object) (metav1.Object, error) {
+	return client.(dynamic.Interface).Resource(u.Resource).Namespace(obj.GetNamespace()).UpdateStatus(obj.(*unstructured.Unstructured), metav1.UpdateOptions{})
+}
+func (u Unstructured) Patch(client interface{}, namespace, name string, pt types.PatchType, data []byte, options metav1.PatchOptions) (metav1.Object, error) {
+	return client.(dynamic.Interface).Resource(u.Resource).Namespace(namespace).Patch(name, pt, data, options)
+}
+func (i UnstructuredInformer) Informer() interface{} { return i.informer }
+func (i UnstructuredInformer) HasSynced() bool       { return i.informer.Informer().HasSynced() }
+func (i UnstructuredInformer) Get(namespace, name string) (metav1.Object, error) {
+	lister := i.informer.Lister()
+	if namespace == "" {
+		obj, err := lister.Get(name)
+		if err != nil {
+			return nil, err
+		}
+		return obj.(metav1.Object), nil
+	}
+
+	obj, err := lister.ByNamespace(namespace).Get(name)
+	if err != nil {
+		return nil, err
+	}
+	return obj.(metav1.Object), nil
+}
+func (i UnstructuredInformer) List() ([]metav1.Object, error) {
+	list, err := i.informer.Lister().List(labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+
+	objs := make([]metav1.Object, len(list))
+	for idx, obj := range list {
+		objs[idx] = obj.(metav1.Object)
+	}
+	return objs, nil
+}
+func (i UnstructuredInformer) AddEventHandler(handler cache.ResourceEventHandler) {
+	i.informer.Informer().AddEventHandler(handler)
+}
+func (i UnstructuredInformer) Start(chanStop <-chan struct{}) { go i.informer.Informer().Run(chanStop) }
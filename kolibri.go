@@ -1,11 +1,77 @@
 package kolibri
 
 import (
+	"context"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+	"k8s.io/client-go/tools/cache"
+
 	"github.com/radiofrance/kolibri/log"
 )
 
 type AnnotationFilter map[string]string
 
+// Kontext is given to all handler functions. It carries the handler logger
+// and a context, derived from the one given to Run and cancelled when the
+// handler stops processing events (like when the controller loses its
+// leadership) or when the call exceeds its deadline (see WithHandlerTimeout).
+// Handler functions can also write the handled objects through it (see
+// UpdateStatus, MergePatch or Apply).
 type Kontext struct {
+	context.Context
 	log.Logger
+
+	// Event describes the event being handled.
+	Event EventMetadata
+
+	// handler is the handler of the event, if any
+	handler *Handler
+}
+
+// EventType is the type of an handled event.
+type EventType string
+
+const (
+	EventCreate    EventType = "create"
+	EventUpdate    EventType = "update"
+	EventDelete    EventType = "delete"
+	EventReplace   EventType = "replace"
+	EventGeneric   EventType = "generic"
+	EventScheduled EventType = "scheduled"
+)
+
+// EventMetadata describes an handled event.
+type EventMetadata struct {
+	Type EventType
+	// Cluster is the name of the cluster the object comes from, empty for
+	// the cluster of the client given to NewController (see InCluster).
+	Cluster string
+	// Key is the key of the object in its cluster, in the `namespace/name`
+	// format (see ObjectKey).
+	Key string
+	// Attempt is the number of the current attempt to handle the event,
+	// starting at 1.
+	Attempt int
+	// Enqueued is the time the event was added to the work queue.
+	Enqueued time.Time
+	// FirstSeen is the time the event was received; for coalesced events
+	// (see WithDebounce), the time the first of them was received.
+	FirstSeen time.Time
+	// InitialList is true if the event comes from the listing of all cached
+	// objects when the handler starts processing events.
+	InitialList bool
+	// Resync is true if the event comes from a periodic resync of the
+	// informer, the object being unchanged.
+	Resync bool
 }
+
+// ObjectKey returns the key of the object, including its cluster.
+func (m EventMetadata) ObjectKey() Key {
+	namespace, name, _ := cache.SplitMetaNamespaceKey(m.Key)
+	return Key{Cluster: m.Cluster, Namespace: namespace, Name: name}
+}
+
+// Span returns the tracing span of the current handler function call (see
+// WithTracing).
+func (k *Kontext) Span() trace.Span { return trace.SpanFromContext(k) }
@@ -0,0 +1,151 @@
+package kolibri
+
+import (
+	"encoding/json"
+
+	jsonpatch "github.com/evanphx/json-patch"
+	"golang.org/x/xerrors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/strategicpatch"
+)
+
+// MergePatch patches the given object, which must be of the handler kind,
+// with a JSON merge patch computed from the modifications of the given
+// function. The function is given a copy of the object; the write is skipped
+// when it does not modify the object. It returns the patched object.
+func (ktx *Kontext) MergePatch(obj metav1.Object, mutate MutateFunc) (metav1.Object, error) {
+	return ktx.patch(obj, mutate, types.MergePatchType)
+}
+
+// StrategicMergePatch is like MergePatch, with a strategic merge patch,
+// merging lists according to the Kubernetes types definitions. It is only
+// supported by typed objects, not by unstructured ones.
+func (ktx *Kontext) StrategicMergePatch(obj metav1.Object, mutate MutateFunc) (metav1.Object, error) {
+	return ktx.patch(obj, mutate, types.StrategicMergePatchType)
+}
+
+func (ktx *Kontext) patch(obj metav1.Object, mutate MutateFunc, pt types.PatchType) (metav1.Object, error) {
+	writer, client, err := ktx.writer()
+	if err != nil {
+		return nil, err
+	}
+
+	modified := copyObject(obj)
+	if err := mutate(modified); err != nil {
+		return nil, err
+	}
+	data, err := createPatch(obj, modified, pt)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to compute patch of '%s/%s': %w", obj.GetNamespace(), obj.GetName(), err)
+	}
+	if string(data) == "{}" {
+		return obj, nil
+	}
+
+	options := metav1.PatchOptions{}
+	if ktx.DryRun() {
+		options.DryRun = []string{metav1.DryRunAll}
+	}
+	patched, err := writer.Patch(client, obj.GetNamespace(), obj.GetName(), pt, data, options)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to patch '%s/%s': %w", obj.GetNamespace(), obj.GetName(), err)
+	}
+	if ktx.DryRun() {
+		ktx.handler.ktr.logDryRun("patch", obj, patched)
+	}
+	return patched, nil
+}
+
+// createPatch returns the patch of the given type turning the original
+// object into the modified one.
+func createPatch(original, modified metav1.Object, pt types.PatchType) ([]byte, error) {
+	originalJSON, err := json.Marshal(original)
+	if err != nil {
+		return nil, err
+	}
+	modifiedJSON, err := json.Marshal(modified)
+	if err != nil {
+		return nil, err
+	}
+
+	if pt == types.StrategicMergePatchType {
+		if _, unstructured := original.(runtime.Unstructured); unstructured {
+			return nil, xerrors.Errorf("strategic merge patches are not supported by unstructured objects")
+		}
+		return strategicpatch.CreateTwoWayMergePatch(originalJSON, modifiedJSON, original)
+	}
+	return jsonpatch.CreateMergePatch(originalJSON, modifiedJSON)
+}
+
+// ApplyOption configures a server-side apply (see Kontext.Apply).
+type ApplyOption func(options *metav1.PatchOptions)
+
+// ForceOwnership makes the controller take the ownership of the fields
+// managed by others in case of conflict, instead of failing.
+func ForceOwnership() ApplyOption {
+	return func(options *metav1.PatchOptions) {
+		force := true
+		options.Force = &force
+	}
+}
+
+// Apply submits the given desired object, which must be of the handler kind,
+// through server-side apply. The desired object only contains the fields
+// managed by the controller, with its name and namespace. The field manager
+// is named after the controller; the apply fails if it conflicts with the
+// fields managed by others, unless ForceOwnership is given. It returns the
+// applied object.
+func (ktx *Kontext) Apply(desired metav1.Object, opts ...ApplyOption) (metav1.Object, error) {
+	writer, client, err := ktx.writer()
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := ktx.applyConfiguration(desired)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to encode '%s/%s': %w", desired.GetNamespace(), desired.GetName(), err)
+	}
+
+	options := metav1.PatchOptions{FieldManager: ktx.handler.ktr.name}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	if ktx.DryRun() {
+		options.DryRun = []string{metav1.DryRunAll}
+	}
+	applied, err := writer.Patch(client, desired.GetNamespace(), desired.GetName(), types.ApplyPatchType, data, options)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to apply '%s/%s': %w", desired.GetNamespace(), desired.GetName(), err)
+	}
+
+	if ktx.DryRun() {
+		// The applied object is compared to the live one, if any
+		current, err := writer.Get(client, desired.GetNamespace(), desired.GetName())
+		if err != nil {
+			current = nil
+		}
+		ktx.handler.ktr.logDryRun("apply", current, applied)
+	}
+	return applied, nil
+}
+
+// applyConfiguration encodes the given desired object, with the handler kind
+// as type (typed objects usually have no type information).
+func (ktx *Kontext) applyConfiguration(desired metav1.Object) ([]byte, error) {
+	data, err := json.Marshal(desired)
+	if err != nil {
+		return nil, err
+	}
+
+	var configuration map[string]interface{}
+	if err := json.Unmarshal(data, &configuration); err != nil {
+		return nil, err
+	}
+	if configuration["apiVersion"] == nil || configuration["kind"] == nil {
+		configuration["apiVersion"] = ktx.handler.kind.APIVersion()
+		configuration["kind"] = ktx.handler.kind.Name()
+	}
+	return json.Marshal(configuration)
+}
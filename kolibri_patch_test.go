@@ -0,0 +1,93 @@
+package kolibri
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/rest"
+)
+
+func TestPatch(t *testing.T) {
+	cached := &corev1.Service{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "a", Labels: map[string]string{"app": "a"}}}
+	client := fake.NewSimpleClientset(cached)
+
+	handler := newFakeHandler(cached)
+	handler.client = client
+	ktx := handler.newEventContext(context.Background(), &updateEvent{baseEvent: &baseEvent{key: "default/a"}})
+
+	patched, err := ktx.MergePatch(cached, func(obj metav1.Object) error {
+		obj.SetLabels(map[string]string{"app": "a", "team": "radio"})
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"app": "a", "team": "radio"}, patched.GetLabels())
+	assert.Equal(t, map[string]string{"app": "a"}, cached.Labels, "Cached objects must not be modified")
+
+	patched, err = ktx.StrategicMergePatch(patched, func(obj metav1.Object) error {
+		obj.(*corev1.Service).Spec.Ports = []corev1.ServicePort{{Name: "http", Port: 80}}
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Len(t, patched.(*corev1.Service).Spec.Ports, 1)
+
+	actions := len(client.Actions())
+	_, err = ktx.MergePatch(patched, func(metav1.Object) error { return nil })
+	require.NoError(t, err)
+	assert.Len(t, client.Actions(), actions, "Unchanged objects must not be written")
+
+	_, err = createPatch(&unstructured.Unstructured{}, &unstructured.Unstructured{}, types.StrategicMergePatchType)
+	assert.Error(t, err, "Strategic merge patches must not be supported by unstructured objects")
+}
+
+func TestApply(t *testing.T) {
+	var force string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPatch, r.Method)
+		assert.Equal(t, "/api/v1/namespaces/default/services/a", r.URL.Path)
+		assert.Equal(t, string(types.ApplyPatchType), r.Header.Get("Content-Type"))
+		assert.Equal(t, "test", r.URL.Query().Get("fieldManager"))
+		force = r.URL.Query().Get("force")
+
+		body, err := ioutil.ReadAll(r.Body)
+		require.NoError(t, err)
+		var configuration map[string]interface{}
+		require.NoError(t, json.Unmarshal(body, &configuration))
+		assert.Equal(t, "v1", configuration["apiVersion"])
+		assert.Equal(t, "Service", configuration["kind"])
+
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(body)
+	}))
+	defer server.Close()
+
+	client, err := kubernetes.NewForConfig(&rest.Config{Host: server.URL})
+	require.NoError(t, err)
+
+	handler := newFakeHandler()
+	handler.ktr.name = "test"
+	handler.client = client
+	ktx := handler.newEventContext(context.Background(), &updateEvent{baseEvent: &baseEvent{key: "default/a"}})
+
+	desired := &corev1.Service{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "a", Labels: map[string]string{"app": "a"}}}
+	applied, err := ktx.Apply(desired)
+	require.NoError(t, err)
+	assert.Equal(t, "a", applied.GetName())
+	assert.Equal(t, map[string]string{"app": "a"}, applied.GetLabels())
+	assert.Empty(t, force, "Ownership must not be forced by default")
+
+	_, err = ktx.Apply(desired, ForceOwnership())
+	require.NoError(t, err)
+	assert.Equal(t, "true", force)
+}
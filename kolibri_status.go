@@ -0,0 +1,180 @@
+package kolibri
+
+import (
+	"time"
+
+	"golang.org/x/xerrors"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/retry"
+
+	"github.com/radiofrance/kolibri/kind"
+)
+
+// MutateFunc modifies a copy of an handled object.
+type MutateFunc func(obj metav1.Object) error
+
+// UpdateStatus updates the status subresource of the given object, which
+// must be of the handler kind, with the modifications of the given function.
+// The function is given a copy of the object; the write is skipped when it
+// does not modify the object.
+//
+// On conflict (when the object is stale, like cached objects often are), the
+// object is read again from the API server and the function called again on
+// it. It returns the updated object.
+func (ktx *Kontext) UpdateStatus(obj metav1.Object, mutate MutateFunc) (metav1.Object, error) {
+	writer, client, err := ktx.writer()
+	if err != nil {
+		return nil, err
+	}
+
+	current := obj
+	var updated metav1.Object
+	err = retry.RetryOnConflict(retry.DefaultBackoff, func() error {
+		if err := ktx.Err(); err != nil {
+			return err
+		}
+
+		desired := copyObject(current)
+		if err := mutate(desired); err != nil {
+			return err
+		}
+		if equality.Semantic.DeepEqual(current, desired) {
+			updated = current
+			return nil
+		}
+
+		if ktx.DryRun() {
+			ktx.handler.ktr.logDryRun("status update", current, desired)
+			updated = desired
+			return nil
+		}
+
+		result, err := writer.UpdateStatus(client, desired)
+		if errors.IsConflict(err) {
+			fresh, getErr := writer.Get(client, obj.GetNamespace(), obj.GetName())
+			if getErr != nil {
+				return getErr
+			}
+			current = fresh
+		}
+		if err != nil {
+			return err
+		}
+		updated = result
+		return nil
+	})
+	if err != nil {
+		return nil, xerrors.Errorf("failed to update status of '%s/%s': %w", obj.GetNamespace(), obj.GetName(), err)
+	}
+	return updated, nil
+}
+
+// writer returns the writer of the handler kind and its client.
+func (ktx *Kontext) writer() (kind.Writer, interface{}, error) {
+	if ktx.handler == nil {
+		return nil, nil, xerrors.Errorf("objects can only be written while handling events")
+	}
+	writer, ok := ktx.handler.kind.(kind.Writer)
+	if !ok {
+		return nil, nil, xerrors.Errorf("kind %s/%s cannot be written", ktx.handler.kind.APIVersion(), ktx.handler.kind.Name())
+	}
+	return writer, ktx.handler.client, nil
+}
+
+// ---------------------------------------------------------------------------------------------------------------//
+// Conditions
+
+// ConditionStatus is the status of a condition.
+type ConditionStatus string
+
+const (
+	ConditionTrue    ConditionStatus = "True"
+	ConditionFalse   ConditionStatus = "False"
+	ConditionUnknown ConditionStatus = "Unknown"
+)
+
+// Condition is an observation of an object state, following the Kubernetes
+// API conventions. It can be embedded in the status of custom resources.
+type Condition struct {
+	// Type of the condition, in CamelCase
+	Type   string          `json:"type"`
+	Status ConditionStatus `json:"status"`
+	// ObservedGeneration is the generation of the object the condition was
+	// set upon
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// LastTransitionTime is the last time the condition status changed
+	LastTransitionTime metav1.Time `json:"lastTransitionTime"`
+	// Reason is a programmatic identifier of the last transition, in
+	// CamelCase
+	Reason string `json:"reason"`
+	// Message is a human readable description of the last transition
+	Message string `json:"message"`
+}
+
+// SetCondition sets the given condition in the conditions, replacing the
+// condition of the same type if any. The transition time is set to now when
+// the condition status changes, unless given. It returns true if the
+// conditions were modified.
+func SetCondition(conditions *[]Condition, condition Condition) bool {
+	existing := FindCondition(*conditions, condition.Type)
+	if existing == nil {
+		if condition.LastTransitionTime.IsZero() {
+			condition.LastTransitionTime = metav1.NewTime(time.Now())
+		}
+		*conditions = append(*conditions, condition)
+		return true
+	}
+
+	if existing.Status == condition.Status {
+		condition.LastTransitionTime = existing.LastTransitionTime
+	} else if condition.LastTransitionTime.IsZero() {
+		condition.LastTransitionTime = metav1.NewTime(time.Now())
+	}
+	if *existing == condition {
+		return false
+	}
+	*existing = condition
+	return true
+}
+
+// RemoveCondition removes the condition of the given type from the
+// conditions. It returns true if the conditions were modified.
+func RemoveCondition(conditions *[]Condition, conditionType string) bool {
+	for i, condition := range *conditions {
+		if condition.Type == conditionType {
+			*conditions = append((*conditions)[:i], (*conditions)[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// FindCondition returns the condition of the given type, or nil.
+func FindCondition(conditions []Condition, conditionType string) *Condition {
+	for i := range conditions {
+		if conditions[i].Type == conditionType {
+			return &conditions[i]
+		}
+	}
+	return nil
+}
+
+// IsConditionTrue returns true if the condition of the given type exists
+// and is true.
+func IsConditionTrue(conditions []Condition, conditionType string) bool {
+	condition := FindCondition(conditions, conditionType)
+	return condition != nil && condition.Status == ConditionTrue
+}
+
+// SetObservedGeneration sets the given observed generation, usually a status
+// field, to the generation of the given object. It returns true if the
+// observed generation was modified.
+func SetObservedGeneration(obj metav1.Object, observedGeneration *int64) bool {
+	if *observedGeneration == obj.GetGeneration() {
+		return false
+	}
+	*observedGeneration = obj.GetGeneration()
+	return true
+}
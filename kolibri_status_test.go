@@ -0,0 +1,93 @@
+package kolibri
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestUpdateStatus(t *testing.T) {
+	cached := &corev1.Service{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "a", ResourceVersion: "1"}}
+	client := fake.NewSimpleClientset(cached)
+
+	var updates int
+	conflicts := 1
+	client.PrependReactor("update", "services", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if action.GetSubresource() != "status" {
+			return false, nil, nil
+		}
+		updates++
+		if conflicts > 0 {
+			conflicts--
+			return true, nil, errors.NewConflict(schema.GroupResource{Resource: "services"}, "a", nil)
+		}
+		return false, nil, nil
+	})
+
+	handler := newFakeHandler(cached)
+	handler.client = client
+	ktx := handler.newEventContext(context.Background(), &updateEvent{baseEvent: &baseEvent{key: "default/a"}})
+
+	var mutations int
+	setIP := func(obj metav1.Object) error {
+		mutations++
+		obj.(*corev1.Service).Status.LoadBalancer.Ingress = []corev1.LoadBalancerIngress{{IP: "10.0.0.1"}}
+		return nil
+	}
+
+	updated, err := ktx.UpdateStatus(cached, setIP)
+	require.NoError(t, err)
+	assert.Equal(t, 2, mutations, "Mutation must be applied again on conflict")
+	assert.Equal(t, 2, updates)
+	assert.Equal(t, "10.0.0.1", updated.(*corev1.Service).Status.LoadBalancer.Ingress[0].IP)
+	assert.Empty(t, cached.Status.LoadBalancer.Ingress, "Cached objects must not be modified")
+
+	_, err = ktx.UpdateStatus(updated, setIP)
+	require.NoError(t, err)
+	assert.Equal(t, 2, updates, "Unchanged objects must not be written")
+
+	_, err = (&Kontext{Context: context.Background()}).UpdateStatus(cached, setIP)
+	assert.Error(t, err, "Objects cannot be written outside of handler functions")
+}
+
+func TestConditions(t *testing.T) {
+	var conditions []Condition
+
+	assert.True(t, SetCondition(&conditions, Condition{Type: "Ready", Status: ConditionFalse, Reason: "Pending"}))
+	require.Len(t, conditions, 1)
+	transition := conditions[0].LastTransitionTime
+	assert.False(t, transition.IsZero())
+	assert.False(t, IsConditionTrue(conditions, "Ready"))
+
+	assert.False(t, SetCondition(&conditions, Condition{Type: "Ready", Status: ConditionFalse, Reason: "Pending"}), "Unchanged conditions must not be modified")
+	assert.True(t, SetCondition(&conditions, Condition{Type: "Ready", Status: ConditionFalse, Reason: "Failed"}))
+	assert.Equal(t, transition, conditions[0].LastTransitionTime, "Transition time must only change with the status")
+
+	since := metav1.NewTime(time.Now().Add(time.Hour))
+	assert.True(t, SetCondition(&conditions, Condition{Type: "Ready", Status: ConditionTrue, LastTransitionTime: since}))
+	assert.Equal(t, since, conditions[0].LastTransitionTime)
+	assert.True(t, IsConditionTrue(conditions, "Ready"))
+
+	assert.True(t, SetCondition(&conditions, Condition{Type: "Degraded", Status: ConditionUnknown}))
+	assert.Len(t, conditions, 2)
+	assert.True(t, RemoveCondition(&conditions, "Ready"))
+	assert.False(t, RemoveCondition(&conditions, "Ready"))
+	assert.Nil(t, FindCondition(conditions, "Ready"))
+	assert.NotNil(t, FindCondition(conditions, "Degraded"))
+
+	var observed int64
+	obj := &corev1.Service{ObjectMeta: metav1.ObjectMeta{Generation: 3}}
+	assert.True(t, SetObservedGeneration(obj, &observed))
+	assert.Equal(t, int64(3), observed)
+	assert.False(t, SetObservedGeneration(obj, &observed))
+}
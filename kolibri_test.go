@@ -0,0 +1,53 @@
+package kolibri
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/xerrors"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestEventMetadata(t *testing.T) {
+	handler := newFakeHandler(&corev1.Service{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "a"}})
+	require.NoError(t, WithDebounce(50*time.Millisecond)(handler))
+
+	type contextKey struct{}
+	ctx := context.WithValue(context.Background(), contextKey{}, "run")
+
+	var events []EventMetadata
+	handler.events.createFuncs = append(handler.events.createFuncs, func(ktx *Kontext, _ metav1.Object) error {
+		assert.Equal(t, "run", ktx.Value(contextKey{}), "Kontext must derive from the given context")
+		events = append(events, ktx.Event)
+		if len(events) == 1 {
+			return xerrors.New("failure")
+		}
+		return nil
+	})
+
+	start := time.Now()
+	handler.enqueueAll()
+	listed := time.Now()
+	time.Sleep(20 * time.Millisecond)
+	updated := time.Now()
+	handler.enqueue(&updateEvent{baseEvent: &baseEvent{kind: "Service", key: "default/a", resync: true}})
+	waitFor(t, func() bool { return handler.queue.Len() == 1 })
+
+	assert.True(t, handler.processNextWorkItem(ctx, handler.queue))
+	assert.True(t, handler.processNextWorkItem(ctx, handler.queue))
+	require.Len(t, events, 2)
+
+	for i, event := range events {
+		assert.Equal(t, EventCreate, event.Type)
+		assert.Equal(t, "default/a", event.Key)
+		assert.Equal(t, i+1, event.Attempt)
+		assert.True(t, event.InitialList, "Coalesced event must come from the initial list")
+		assert.False(t, event.Resync, "Coalesced event must not come from a resync")
+		assert.False(t, event.FirstSeen.Before(start) || event.FirstSeen.After(listed), "Coalesced event must be first seen when listed")
+		assert.False(t, event.Enqueued.Before(updated.Add(50*time.Millisecond)), "Event must be enqueued after its debounce window")
+	}
+}
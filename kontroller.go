@@ -3,10 +3,15 @@ package kolibri
 import (
 	"context"
 	"reflect"
+	"time"
 
+	"go.opentelemetry.io/otel/trace"
 	"golang.org/x/sync/errgroup"
+	"golang.org/x/xerrors"
 	"k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/util/workqueue"
 
 	"github.com/radiofrance/kolibri/log"
 	"github.com/radiofrance/kolibri/log/fake"
@@ -17,38 +22,161 @@ type Kontroller struct {
 
 	log.Logger
 	kube     kubernetes.Interface
-	handlers []*Handler
+	dynamic  dynamic.Interface
+	registry *registry
+
+	// cluster is the name of the cluster of the kube client, empty for the
+	// client given to NewController (see WithCluster)
+	cluster  string
+	clusters *clusters
+
+	// Handlers defaults, which can be overridden by each handler
+	policy      UpdateHandlerPolicy
+	resync      time.Duration
+	workers     int
+	rateLimiter func() workqueue.RateLimiter
+
+	election *leaderElection
+	sharding *sharding
+	metrics  *metrics
+	health   *health
+	server   *httpServer
+	adminAPI bool
+
+	tracerProvider trace.TracerProvider
+	crashOnPanic   bool
+	errorHandler   ErrorHandler
+	dryRun         bool
+	hooks          hooks
 }
 
-func NewController(name string, client kubernetes.Interface, opts ...interface{}) *Kontroller {
-	return &Kontroller{
-		name:   name,
-		Logger: fake.New(),
-		kube:   client,
+// ControllerOption configures the controller. Some of them (like WithLogger
+// or WithWorkers) can also be given to NewHandler, overriding the controller
+// defaults for this handler only.
+type ControllerOption interface {
+	applyController(k *Kontroller) error
+}
+
+// controllerOption wraps functions used to configure the controller itself.
+type controllerOption func(k *Kontroller) error
+
+func (o controllerOption) applyController(k *Kontroller) error { return o(k) }
+
+func NewController(name string, client kubernetes.Interface, opts ...ControllerOption) (*Kontroller, error) {
+	k := &Kontroller{
+		name:        name,
+		Logger:      fake.New(),
+		kube:        client,
+		registry:    newRegistry(),
+		clusters:    newClusters(),
+		health:      newHealth(),
+		resync:      5 * time.Second,
+		workers:     10,
+		rateLimiter: workqueue.DefaultControllerRateLimiter,
+		hooks:       hooks{},
 	}
+	trackReflectorFailures()
+
+	for _, opt := range opts {
+		if err := opt.applyController(k); err != nil {
+			return nil, err
+		}
+	}
+	if err := k.serveAdminAPI(); err != nil {
+		return nil, err
+	}
+	return k, nil
 }
 
 func (k *Kontroller) SetLogger(logger log.Logger) { k.Logger = logger }
-func (k *Kontroller) Register(handlers ...*Handler) error {
-	k.handlers = append(k.handlers, handlers...)
-	return nil
-}
+
+// Run starts all registered handlers and processes their events until the
+// context is done (see HookFunc for the lifecycle). Events are only processed
+// once the caches of all handlers are synced.
 func (k *Kontroller) Run(ctx context.Context) error {
 	errg, ctx := errgroup.WithContext(ctx)
 
-	for _, handler := range k.handlers {
-		errg.Go(func() error { return handler.Run(ctx) })
+	if k.server != nil {
+		errg.Go(func() error { return k.server.run(ctx) })
 	}
+	errg.Go(func() error { return k.run(ctx) })
 
 	return errg.Wait()
 }
 
-func (k *Kontroller) newContext(name string) *Kontext { return &Kontext{k} }
-func (k *Kontroller) handleError(err error)           {}
+// process processes the events of all registered handlers until the
+// context is done, including the handlers registered meanwhile.
+func (k *Kontroller) process(ctx context.Context) {
+	k.hooks.runAndLog(k.newContext(ctx, "hooks"), hookStartedLeading)
+
+	term := k.registry.startTerm(ctx)
+	<-ctx.Done()
+	k.registry.endTerm()
+	term.handlers.Wait()
 
-func (k *Kontroller) copy() *Kontroller { return k }
+	k.hooks.runAndLog(k.newContext(context.Background(), "hooks"), hookStoppedLeading)
+}
 
-func (k *Kontroller) setUpdatePolicy(policy UpdateHandlerPolicy)      { return }
-func (k *Kontroller) updatePolicy(old v1.Object, curr v1.Object) bool { return false }
+// newContext returns a Kontext, with a logger named after the given name.
+func (k *Kontroller) newContext(ctx context.Context, name string) *Kontext {
+	return &Kontext{Context: ctx, Logger: k.Named(name)}
+}
 
-func (k *Kontroller) client(clientType reflect.Type) (interface{}, error) { return k.kube, nil }
+// newEventContext returns the Kontext given to the handler functions
+// handling the given event.
+func (k *Kontroller) newEventContext(ctx context.Context, container eventContainer) *Kontext {
+	event := metadata(container)
+	event.Cluster = k.cluster
+	logger := k.With(log.String("key", k.clusterKey(event.Key)), log.String("event", string(event.Type)), log.Int("attempt", event.Attempt))
+	return &Kontext{Context: ctx, Logger: logger, Event: event}
+}
+
+// copy returns a shallow copy of the controller, used by handlers to
+// override some controller properties (logger, update policy, ...) without
+// affecting the others.
+func (k *Kontroller) copy() *Kontroller {
+	c := *k
+	return &c
+}
+
+func (k *Kontroller) setUpdatePolicy(policy UpdateHandlerPolicy) { k.policy = policy }
+func (k *Kontroller) updatePolicy(old v1.Object, curr v1.Object) bool {
+	if k.policy == nil {
+		return defaultUpdatePolicy(old, curr)
+	}
+	return k.policy(old, curr)
+}
+
+// defaultUpdatePolicy considers an object as updated when its resource
+// version has changed.
+func defaultUpdatePolicy(old, curr v1.Object) bool {
+	return old.GetResourceVersion() != curr.GetResourceVersion()
+}
+
+// dynamicClientType is the client type of the unstructured kinds (see
+// kind.Unstructured).
+var dynamicClientType = reflect.TypeOf((*dynamic.Interface)(nil)).Elem()
+
+// WithDynamicClient sets the dynamic client used by the handlers of
+// unstructured kinds (see kind.Unstructured), which must target the cluster
+// of the client given to NewController. Unstructured kinds are not supported
+// in named clusters (see WithCluster).
+func WithDynamicClient(client dynamic.Interface) controllerOption {
+	return func(k *Kontroller) error {
+		if client == nil {
+			return xerrors.Errorf("dynamic client cannot be nil")
+		}
+		k.dynamic = client
+		return nil
+	}
+}
+
+func (k *Kontroller) client(clientType reflect.Type) (interface{}, error) {
+	if clientType == dynamicClientType {
+		if k.dynamic == nil {
+			return nil, xerrors.Errorf("unstructured kinds require a dynamic client of the cluster (see WithDynamicClient)")
+		}
+		return k.dynamic, nil
+	}
+	return k.kube, nil
+}
@@ -0,0 +1,243 @@
+package kolibri
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"golang.org/x/xerrors"
+)
+
+// WithAdminAPI serves an admin API, on the controller HTTP server (see
+// WithHTTPServer), used to inspect and steer the registered handlers:
+//   - GET  /debug/handlers lists the handlers, with their kind, namespace,
+//     workers count, queue depth and in-flight events
+//   - POST /debug/handlers/<name>/requeue?key=<namespace/name> enqueues an
+//     update event for the given key, if handled by this replica (see
+//     WithSharding)
+//   - POST /debug/handlers/<name>/drop?key=<namespace/name> drops all events
+//     of the given key currently waiting in the queue or being retried
+//   - POST /debug/handlers/<name>/pause pauses the handler (see Handler.Pause)
+//   - POST /debug/handlers/<name>/resume resumes the handler (see Handler.Resume)
+//
+// Keys are served and accepted in the Key.String format, qualified by the
+// cluster for the handlers of named clusters (see InCluster); the cluster
+// can be omitted in the accepted keys.
+//
+// This API must not be exposed publicly.
+func WithAdminAPI() controllerOption {
+	return func(k *Kontroller) error {
+		k.adminAPI = true
+		return nil
+	}
+}
+
+// serveAdminAPI adds the admin API routes to the HTTP server, once all
+// controller options are applied.
+func (k *Kontroller) serveAdminAPI() error {
+	if !k.adminAPI {
+		return nil
+	}
+	if k.server == nil {
+		return xerrors.Errorf("admin API requires an HTTP server (see WithHTTPServer)")
+	}
+
+	k.server.mux.HandleFunc("/debug/handlers", k.serveHandlers)
+	k.server.mux.HandleFunc("/debug/handlers/", k.serveHandlerAction)
+	return nil
+}
+
+// HandlerStatus describes the state of an handler, as served by the admin
+// API.
+type HandlerStatus struct {
+	Name       string           `json:"name"`
+	Kind       string           `json:"kind"`
+	APIVersion string           `json:"apiVersion"`
+	Namespace  string           `json:"namespace,omitempty"`
+	Cluster    string           `json:"cluster,omitempty"`
+	Workers    int              `json:"workers"`
+	Processing bool             `json:"processing"`
+	Paused     bool             `json:"paused"`
+	QueueDepth int              `json:"queueDepth"`
+	HeldEvents int              `json:"heldEvents"`
+	Inflight   []InflightStatus `json:"inflight"`
+}
+
+// InflightStatus describes an event currently handled.
+type InflightStatus struct {
+	Type    EventType `json:"type"`
+	Key     string    `json:"key"`
+	Since   time.Time `json:"since"`
+	Retries int       `json:"retries"`
+}
+
+// status returns the current state of the handler.
+func (h *Handler) status() HandlerStatus {
+	status := HandlerStatus{
+		Name:       h.name,
+		Kind:       h.kind.Name(),
+		APIVersion: h.kind.APIVersion(),
+		Namespace:  h.namespace,
+		Cluster:    h.Cluster(),
+		Workers:    h.workers,
+		Paused:     h.Paused(),
+		HeldEvents: h.heldEvents(),
+		Inflight:   []InflightStatus{},
+	}
+
+	queue := h.workqueue()
+	if queue != nil {
+		status.Processing = true
+		status.QueueDepth = queue.Len()
+	}
+
+	h.inflightMu.Lock()
+	for event, since := range h.inflight {
+		inflight := InflightStatus{Type: eventType(event), Key: h.ktr.clusterKey(event.Key()), Since: since}
+		if queue != nil {
+			inflight.Retries = queue.NumRequeues(event)
+		}
+		status.Inflight = append(status.Inflight, inflight)
+	}
+	h.inflightMu.Unlock()
+
+	sort.Slice(status.Inflight, func(i, j int) bool { return status.Inflight[i].Since.Before(status.Inflight[j].Since) })
+	return status
+}
+
+// cacheKey returns the cache key of the given key, in the Key.String format,
+// which must belong to the handler cluster; keys without cluster belong to
+// the handler cluster.
+func (h *Handler) cacheKey(s string) (string, error) {
+	key, err := parseKey(s)
+	if err != nil {
+		return "", err
+	}
+	if key.Cluster != "" && key.Cluster != h.Cluster() {
+		return "", xerrors.Errorf("key '%s' does not belong to the cluster of handler '%s'", s, h.name)
+	}
+	key.Cluster = ""
+	return key.String(), nil
+}
+
+// requeue enqueues an update event for the given key, which fails if the
+// key belongs to another shard.
+func (h *Handler) requeue(key string) error {
+	if h.workqueue() == nil {
+		return xerrors.Errorf("handler '%s' does not process events", h.name)
+	}
+	if !h.add(&updateEvent{baseEvent: &baseEvent{kind: h.kind.Name(), key: key}}) {
+		return xerrors.Errorf("key '%s' is not handled by this replica", h.ktr.clusterKey(key))
+	}
+	return nil
+}
+
+// drop drops all events of the given key added until now, waiting in the
+// queue, being retried, debounced or held. Events currently handled are not
+// cancelled but will not be retried.
+func (h *Handler) drop(key string) {
+	h.droppedMu.Lock()
+	h.dropped[key] = time.Now()
+	h.droppedMu.Unlock()
+
+	h.release(key)
+	if h.debouncer != nil {
+		h.debouncer.discard(key)
+	}
+}
+
+// isDropped returns true if the given event has been dropped.
+func (h *Handler) isDropped(event eventContainer) bool {
+	h.droppedMu.Lock()
+	defer h.droppedMu.Unlock()
+
+	at, dropped := h.dropped[event.Key()]
+	return dropped && !event.addedAt().After(at)
+}
+
+// resetDropped forgets all dropped keys, used when a new queue is created.
+func (h *Handler) resetDropped() {
+	h.droppedMu.Lock()
+	defer h.droppedMu.Unlock()
+	h.dropped = map[string]time.Time{}
+}
+
+// ---------------------------------------------------------------------------------------------------------------//
+// HTTP handlers
+
+func (k *Kontroller) serveHandlers(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	statuses := []HandlerStatus{}
+	for _, handler := range k.registered() {
+		statuses = append(statuses, handler.status())
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(statuses)
+}
+
+func (k *Kontroller) serveHandlerAction(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/debug/handlers/"), "/")
+	if len(parts) != 2 {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	handler := k.handler(parts[0])
+	if handler == nil {
+		http.Error(w, "handler '"+parts[0]+"' not found", http.StatusNotFound)
+		return
+	}
+	key := r.URL.Query().Get("key")
+	if parts[1] == "requeue" || parts[1] == "drop" {
+		if key == "" {
+			http.Error(w, "key must be provided", http.StatusBadRequest)
+			return
+		}
+		var err error
+		if key, err = handler.cacheKey(key); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	switch parts[1] {
+	case "pause":
+		handler.Pause()
+	case "resume":
+		handler.Resume()
+	case "requeue":
+		if err := handler.requeue(key); err != nil {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		k.Infof("key '%s' of handler '%s' requeued through the admin API", handler.ktr.clusterKey(key), handler.name)
+	case "drop":
+		handler.drop(key)
+		k.Infof("key '%s' of handler '%s' dropped through the admin API", handler.ktr.clusterKey(key), handler.name)
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// handler returns the registered handler with the given name, if any.
+func (k *Kontroller) handler(name string) *Handler {
+	for _, handler := range k.registered() {
+		if handler.name == name {
+			return handler
+		}
+	}
+	return nil
+}
@@ -0,0 +1,142 @@
+package kolibri
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestAdminAPI(t *testing.T) {
+	_, err := NewController("test", fake.NewSimpleClientset(), WithAdminAPI())
+	assert.Error(t, err, "Admin API without HTTP server must fail")
+
+	ktr, err := NewController("test", fake.NewSimpleClientset(), WithAdminAPI(), WithHTTPServer(":0"))
+	require.NoError(t, err, "Admin API must not depend on the options order")
+
+	var handled []string
+	handler := newFakeHandler(
+		&corev1.Service{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "a"}},
+		&corev1.Service{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "b"}},
+	)
+	handler.namespace = "default"
+	handler.owner = ktr
+	handler.events.updateFuncs = append(handler.events.updateFuncs, func(_ *Kontext, obj metav1.Object) error {
+		handled = append(handled, obj.GetName())
+		return nil
+	})
+	require.NoError(t, ktr.Register(handler))
+
+	call := func(method, path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		ktr.server.mux.ServeHTTP(rec, httptest.NewRequest(method, path, nil))
+		return rec
+	}
+
+	// Steer the handler
+	assert.Equal(t, http.StatusAccepted, call(http.MethodPost, "/debug/handlers/service/requeue?key=default/a").Code)
+	assert.Equal(t, http.StatusAccepted, call(http.MethodPost, "/debug/handlers/service/requeue?key=default/b").Code)
+	assert.Equal(t, http.StatusAccepted, call(http.MethodPost, "/debug/handlers/service/drop?key=default/a").Code)
+	assert.Equal(t, http.StatusNotFound, call(http.MethodPost, "/debug/handlers/unknown/drop?key=default/a").Code)
+	assert.Equal(t, http.StatusNotFound, call(http.MethodPost, "/debug/handlers/service/unknown?key=default/a").Code)
+	assert.Equal(t, http.StatusBadRequest, call(http.MethodPost, "/debug/handlers/service/drop").Code)
+	assert.Equal(t, http.StatusAccepted, call(http.MethodPost, "/debug/handlers/service/pause").Code)
+	assert.True(t, handler.Paused())
+	assert.Equal(t, http.StatusAccepted, call(http.MethodPost, "/debug/handlers/service/resume").Code)
+	assert.False(t, handler.Paused())
+	assert.Equal(t, http.StatusMethodNotAllowed, call(http.MethodGet, "/debug/handlers/service/drop?key=default/a").Code)
+
+	// Inspect the handler
+	event := &updateEvent{baseEvent: &baseEvent{key: "default/c"}}
+	handler.setInflight(event, true)
+	handler.queue.AddRateLimited(event)
+
+	rec := call(http.MethodGet, "/debug/handlers")
+	require.Equal(t, http.StatusOK, rec.Code)
+	var statuses []HandlerStatus
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&statuses))
+	require.Len(t, statuses, 1)
+	assert.Equal(t, "service", statuses[0].Name)
+	assert.Equal(t, "Service", statuses[0].Kind)
+	assert.Equal(t, "default", statuses[0].Namespace)
+	assert.Equal(t, 10, statuses[0].Workers)
+	assert.Equal(t, 2, statuses[0].QueueDepth)
+	require.Len(t, statuses[0].Inflight, 1)
+	assert.Equal(t, EventUpdate, statuses[0].Inflight[0].Type)
+	assert.Equal(t, "default/c", statuses[0].Inflight[0].Key)
+	assert.Equal(t, 1, statuses[0].Inflight[0].Retries)
+	handler.setInflight(event, false)
+
+	// Dropped keys are not handled, until a new event is received
+	ctx := context.Background()
+	assert.True(t, handler.processNextWorkItem(ctx, handler.queue))
+	assert.True(t, handler.processNextWorkItem(ctx, handler.queue))
+	assert.Equal(t, []string{"b"}, handled)
+
+	// The retried default/c event is handled too
+	assert.NoError(t, handler.requeue("default/a"))
+	assert.True(t, handler.processNextWorkItem(ctx, handler.queue))
+	assert.True(t, handler.processNextWorkItem(ctx, handler.queue))
+	assert.Equal(t, []string{"b", "a"}, handled)
+}
+
+func TestAdminAPIClusterKeys(t *testing.T) {
+	ktr, err := NewController("test", fake.NewSimpleClientset(), WithHTTPServer(":0"), WithAdminAPI())
+	require.NoError(t, err)
+
+	handler := newFakeHandler()
+	handler.ktr.cluster = "eu"
+	handler.owner = ktr
+	require.NoError(t, ktr.Register(handler))
+
+	call := func(method, path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		ktr.server.mux.ServeHTTP(rec, httptest.NewRequest(method, path, nil))
+		return rec
+	}
+
+	assert.Equal(t, http.StatusAccepted, call(http.MethodPost, "/debug/handlers/service/requeue?key=default/a@eu").Code)
+	assert.Equal(t, http.StatusAccepted, call(http.MethodPost, "/debug/handlers/service/requeue?key=default/b").Code, "Keys of the handler cluster can omit it")
+	assert.Equal(t, http.StatusBadRequest, call(http.MethodPost, "/debug/handlers/service/requeue?key=default/a@us").Code, "Keys of other clusters must be rejected")
+	assert.Equal(t, http.StatusBadRequest, call(http.MethodPost, "/debug/handlers/service/drop?key=default/a/b").Code)
+
+	var keys []string
+	for handler.queue.Len() > 0 {
+		event, _ := handler.queue.Get()
+		handler.queue.Done(event)
+		keys = append(keys, event.(eventContainer).Key())
+	}
+	assert.Equal(t, []string{"default/a", "default/b"}, keys)
+
+	handler.setInflight(&updateEvent{baseEvent: &baseEvent{key: "default/c"}}, true)
+	rec := call(http.MethodGet, "/debug/handlers")
+	var statuses []HandlerStatus
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&statuses))
+	require.Len(t, statuses, 1)
+	assert.Equal(t, "eu", statuses[0].Cluster)
+	require.Len(t, statuses[0].Inflight, 1)
+	assert.Equal(t, "default/c@eu", statuses[0].Inflight[0].Key, "Served keys must include the cluster")
+}
+
+func TestAdminAPISharding(t *testing.T) {
+	ktr, err := NewController("test", fake.NewSimpleClientset(), WithHTTPServer(":0"), WithAdminAPI())
+	require.NoError(t, err)
+
+	handler := newFakeHandler()
+	handler.ktr.sharding = &sharding{identity: "a"}
+	handler.ktr.sharding.ring.Store(newHashRing([]string{"b"}))
+	handler.owner = ktr
+	require.NoError(t, ktr.Register(handler))
+
+	rec := httptest.NewRecorder()
+	ktr.server.mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/debug/handlers/service/requeue?key=default/a", nil))
+	assert.Equal(t, http.StatusConflict, rec.Code, "Keys of other replicas must not be requeued")
+	assert.Equal(t, 0, handler.queue.Len())
+}
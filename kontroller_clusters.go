@@ -0,0 +1,234 @@
+package kolibri
+
+import (
+	"sort"
+	"sync"
+
+	"golang.org/x/xerrors"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/radiofrance/kolibri/log"
+)
+
+// clusters contains the named clusters of the controller, in addition to the
+// cluster of the client given to NewController.
+type clusters struct {
+	mu      sync.Mutex
+	clients map[string]kubernetes.Interface
+	// builders build the handlers of each cluster (see ForEachCluster)
+	builders []ClusterHandlersFunc
+
+	// membership serializes the runtime membership changes, so that they
+	// can be rolled back
+	membership sync.Mutex
+}
+
+func newClusters() *clusters {
+	return &clusters{clients: map[string]kubernetes.Interface{}}
+}
+
+// WithCluster adds a named cluster to the controller, which handlers can be
+// bound to (see InCluster). Leader election and sharding always use the
+// client given to NewController.
+func WithCluster(name string, client kubernetes.Interface) controllerOption {
+	return func(k *Kontroller) error {
+		return k.clusters.add(name, client)
+	}
+}
+
+func (c *clusters) add(name string, client kubernetes.Interface) error {
+	if name == "" || client == nil {
+		return xerrors.Errorf("cluster name and client must be provided")
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, exists := c.clients[name]; exists {
+		return xerrors.Errorf("cluster '%s' already exists", name)
+	}
+	c.clients[name] = client
+	return nil
+}
+
+// client returns the client of the given cluster.
+func (c *clusters) client(name string) (kubernetes.Interface, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	client, exists := c.clients[name]
+	if !exists {
+		return nil, xerrors.Errorf("unknown cluster '%s'", name)
+	}
+	return client, nil
+}
+
+// clusterOption binds the handler to a named cluster.
+type clusterOption string
+
+func (o clusterOption) apply(ctx *handlerBuildContext) error {
+	if o == "" {
+		return xerrors.Errorf("cluster name cannot be empty")
+	}
+	ctx.cluster = string(o)
+	return nil
+}
+
+// InCluster binds the handler to the given cluster (see WithCluster): its
+// informers watch this cluster and its writes are sent to it. The cluster is
+// available through the Kontext; the handler is named `<kind>@<cluster>` by
+// default.
+func InCluster(name string) clusterOption { return clusterOption(name) }
+
+// Cluster returns the name of the cluster the handler is bound to, empty for
+// the cluster of the client given to NewController.
+func (h *Handler) Cluster() string { return h.ktr.cluster }
+
+// Client returns the client of the cluster the handled event comes from, nil
+// outside of handler functions.
+func (ktx *Kontext) Client() kubernetes.Interface {
+	if ktx.handler == nil {
+		return nil
+	}
+	return ktx.handler.ktr.kube
+}
+
+// clusterKey returns the given object key, qualified by the cluster name for
+// the named clusters (see Key.String).
+func (k *Kontroller) clusterKey(key string) string {
+	if k.cluster == "" {
+		return key
+	}
+	return key + "@" + k.cluster
+}
+
+// ---------------------------------------------------------------------------------------------------------------//
+// Runtime membership
+
+// ClusterHandlersFunc builds the handlers of the given cluster, binding them
+// to it with InCluster.
+type ClusterHandlersFunc func(cluster string) ([]*Handler, error)
+
+// ForEachCluster registers the handlers built by the given function for each
+// named cluster, including the ones added later with AddCluster. If the
+// handlers of a cluster cannot be registered, the ones of the other clusters
+// are unregistered and the function is discarded.
+func (k *Kontroller) ForEachCluster(fnc ClusterHandlersFunc) error {
+	if fnc == nil {
+		return xerrors.Errorf("cluster handlers function cannot be nil")
+	}
+
+	k.clusters.membership.Lock()
+	defer k.clusters.membership.Unlock()
+
+	k.clusters.mu.Lock()
+	var names []string
+	for name := range k.clusters.clients {
+		names = append(names, name)
+	}
+	k.clusters.mu.Unlock()
+
+	sort.Strings(names)
+	var registered []*Handler
+	for _, name := range names {
+		handlers, err := k.registerCluster(name, fnc)
+		if err != nil {
+			k.rollbackCluster(registered)
+			return err
+		}
+		registered = append(registered, handlers...)
+	}
+
+	k.clusters.mu.Lock()
+	defer k.clusters.mu.Unlock()
+	k.clusters.builders = append(k.clusters.builders, fnc)
+	return nil
+}
+
+// AddCluster adds a named cluster to the controller, registering its handlers
+// (see ForEachCluster); they are started at once if the controller runs. If
+// its handlers cannot be registered, the cluster is not added.
+func (k *Kontroller) AddCluster(name string, client kubernetes.Interface) error {
+	k.clusters.membership.Lock()
+	defer k.clusters.membership.Unlock()
+
+	if err := k.clusters.add(name, client); err != nil {
+		return err
+	}
+
+	k.clusters.mu.Lock()
+	builders := append([]ClusterHandlersFunc{}, k.clusters.builders...)
+	k.clusters.mu.Unlock()
+
+	var registered []*Handler
+	for _, fnc := range builders {
+		handlers, err := k.registerCluster(name, fnc)
+		if err != nil {
+			k.rollbackCluster(registered)
+			k.clusters.remove(name)
+			return err
+		}
+		registered = append(registered, handlers...)
+	}
+	return nil
+}
+
+// RemoveCluster removes a named cluster from the controller, unregistering
+// all handlers bound to it (see Unregister).
+func (k *Kontroller) RemoveCluster(name string) error {
+	k.clusters.membership.Lock()
+	defer k.clusters.membership.Unlock()
+
+	if _, err := k.clusters.client(name); err != nil {
+		return err
+	}
+
+	var handlers []*Handler
+	for _, handler := range k.registered() {
+		if handler.Cluster() == name {
+			handlers = append(handlers, handler)
+		}
+	}
+	if len(handlers) > 0 {
+		if err := k.Unregister(handlers...); err != nil {
+			return xerrors.Errorf("failed to remove cluster '%s': %w", name, err)
+		}
+	}
+
+	k.clusters.remove(name)
+	return nil
+}
+
+func (c *clusters) remove(name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.clients, name)
+}
+
+// registerCluster registers the handlers built by the given function for the
+// given cluster, returning them.
+func (k *Kontroller) registerCluster(name string, fnc ClusterHandlersFunc) ([]*Handler, error) {
+	handlers, err := fnc(name)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to build handlers of cluster '%s': %w", name, err)
+	}
+	for _, handler := range handlers {
+		if handler != nil && handler.Cluster() != name {
+			return nil, xerrors.Errorf("handler '%s' is not bound to cluster '%s'", handler.name, name)
+		}
+	}
+	if err := k.Register(handlers...); err != nil {
+		return nil, err
+	}
+	return handlers, nil
+}
+
+// rollbackCluster unregisters the given handlers, registered by a failed
+// membership change.
+func (k *Kontroller) rollbackCluster(handlers []*Handler) {
+	if len(handlers) == 0 {
+		return
+	}
+	if err := k.Unregister(handlers...); err != nil {
+		k.With(log.Error("err", err)).Errorf("failed to unregister the handlers of a failed cluster membership change")
+	}
+}
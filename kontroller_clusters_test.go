@@ -0,0 +1,132 @@
+package kolibri
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/xerrors"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/radiofrance/kolibri/kind"
+)
+
+func TestWithCluster(t *testing.T) {
+	eu := fake.NewSimpleClientset()
+	_, err := NewController("test", fake.NewSimpleClientset(), WithCluster("", eu))
+	assert.Error(t, err)
+	_, err = NewController("test", fake.NewSimpleClientset(), WithCluster("eu", nil))
+	assert.Error(t, err)
+	_, err = NewController("test", fake.NewSimpleClientset(), WithCluster("eu", eu), WithCluster("eu", eu))
+	assert.Error(t, err, "Cluster names must be unique")
+
+	ktr, err := NewController("test", fake.NewSimpleClientset(), WithCluster("eu", eu))
+	require.NoError(t, err)
+
+	onCreate := OnCreate(func(*Kontext, metav1.Object) error { return nil })
+	_, err = ktr.NewHandler(Kind(&kind.Service{}), onCreate, InCluster("us"))
+	assert.Error(t, err, "Unknown clusters must be rejected")
+
+	home, err := ktr.NewHandler(Kind(&kind.Service{}), onCreate)
+	require.NoError(t, err)
+	handler, err := ktr.NewHandler(Kind(&kind.Service{}), onCreate, InCluster("eu"))
+	require.NoError(t, err)
+	assert.Equal(t, "service@eu", handler.Name())
+	assert.Equal(t, "eu", handler.Cluster())
+	assert.Equal(t, kubernetes.Interface(eu), handler.client)
+	assert.Equal(t, "default/a@eu", handler.ktr.clusterKey("default/a"))
+	assert.Equal(t, "default/a", home.ktr.clusterKey("default/a"))
+
+	require.NoError(t, ktr.Register(home, handler), "Handlers of different clusters must not conflict")
+}
+
+func TestClusterMembership(t *testing.T) {
+	ktr, err := NewController("test", fake.NewSimpleClientset())
+	require.NoError(t, err)
+
+	var mu sync.Mutex
+	handled := map[string]kubernetes.Interface{}
+	require.NoError(t, ktr.ForEachCluster(func(cluster string) ([]*Handler, error) {
+		handler, err := ktr.NewHandler(
+			Kind(&kind.Service{}),
+			InCluster(cluster),
+			OnCreate(func(ktx *Kontext, obj metav1.Object) error {
+				mu.Lock()
+				defer mu.Unlock()
+				handled[ktx.Event.Cluster+"/"+obj.GetName()] = ktx.Client()
+				return nil
+			}),
+		)
+		return []*Handler{handler}, err
+	}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- ktr.Run(ctx) }()
+
+	us := fake.NewSimpleClientset(&corev1.Service{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "a"}})
+	require.NoError(t, ktr.AddCluster("us", us))
+	assert.Error(t, ktr.AddCluster("us", us))
+	require.Len(t, ktr.registered(), 1)
+
+	waitFor(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return handled["us/a"] != nil
+	}, "Handlers of clusters added at runtime must process events")
+	mu.Lock()
+	assert.Equal(t, kubernetes.Interface(us), handled["us/a"], "Kontext must expose the event cluster client")
+	mu.Unlock()
+
+	require.NoError(t, ktr.RemoveCluster("us"))
+	assert.Empty(t, ktr.registered(), "Handlers of removed clusters must be unregistered")
+	assert.Error(t, ktr.RemoveCluster("us"))
+
+	cancel()
+	assert.NoError(t, <-done)
+}
+
+func TestClusterRollback(t *testing.T) {
+	ktr, err := NewController("test", fake.NewSimpleClientset(), WithCluster("eu", fake.NewSimpleClientset()))
+	require.NoError(t, err)
+
+	onCreate := OnCreate(func(*Kontext, metav1.Object) error { return nil })
+	services := func(cluster string) ([]*Handler, error) {
+		handler, err := ktr.NewHandler(Kind(&kind.Service{}), InCluster(cluster), onCreate)
+		return []*Handler{handler}, err
+	}
+	failing := func(cluster string) ([]*Handler, error) {
+		if cluster == "us" {
+			return nil, xerrors.New("failure")
+		}
+		handler, err := ktr.NewHandler(Kind(&kind.Ingress{}), InCluster(cluster), onCreate)
+		return []*Handler{handler}, err
+	}
+
+	require.NoError(t, ktr.ForEachCluster(services))
+	require.Len(t, ktr.registered(), 1)
+	require.NoError(t, ktr.AddCluster("us", fake.NewSimpleClientset()))
+	require.Len(t, ktr.registered(), 2)
+
+	assert.Error(t, ktr.ForEachCluster(failing))
+	assert.Len(t, ktr.registered(), 2, "Handlers of other clusters must be unregistered")
+	require.NoError(t, ktr.RemoveCluster("us"))
+	require.NoError(t, ktr.AddCluster("us", fake.NewSimpleClientset()))
+	assert.Len(t, ktr.registered(), 2, "Failed functions must be discarded")
+
+	require.NoError(t, ktr.ForEachCluster(func(cluster string) ([]*Handler, error) {
+		if cluster == "ap" {
+			return nil, xerrors.New("failure")
+		}
+		return nil, nil
+	}))
+	assert.Error(t, ktr.AddCluster("ap", fake.NewSimpleClientset()))
+	assert.Len(t, ktr.registered(), 2, "Handlers of failed clusters must be unregistered")
+	_, err = ktr.clusters.client("ap")
+	assert.Error(t, err, "Failed clusters must not be added")
+}
@@ -0,0 +1,42 @@
+package kolibri
+
+import (
+	"reflect"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/diff"
+
+	"github.com/radiofrance/kolibri/log"
+)
+
+// WithDryRun runs the controller without side effects on the cluster: the
+// writes made through the kolibri helpers (finalizers, UpdateStatus, patches
+// and Apply) are logged as a diff of the intended change. Patches and Apply
+// are sent with dryRun=All, so they are validated by the API server without
+// being persisted; updates, which the typed clients cannot send in dry-run,
+// are not sent at all.
+//
+// Handler functions are still called; they can check Kontext.DryRun to skip
+// their own side effects.
+func WithDryRun() controllerOption {
+	return func(k *Kontroller) error {
+		k.dryRun = true
+		return nil
+	}
+}
+
+// DryRun returns true if the controller runs in dry-run mode (see
+// WithDryRun).
+func (ktx *Kontext) DryRun() bool {
+	return ktx.handler != nil && ktx.handler.ktr.dryRun
+}
+
+// logDryRun logs the change intended by a write made in dry-run mode. The
+// original object can be nil, for creations.
+func (k *Kontroller) logDryRun(verb string, original, desired metav1.Object) {
+	if original == nil || reflect.ValueOf(original).IsNil() {
+		original = reflect.New(reflect.TypeOf(desired).Elem()).Interface().(metav1.Object)
+	}
+	k.With(log.String("diff", diff.ObjectReflectDiff(original, desired))).
+		Infof("dry-run: %s '%s/%s'", verb, desired.GetNamespace(), desired.GetName())
+}
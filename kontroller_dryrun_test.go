@@ -0,0 +1,90 @@
+package kolibri
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/rest"
+
+	"github.com/radiofrance/kolibri/log/kzap"
+)
+
+func TestDryRunUpdates(t *testing.T) {
+	cached := &corev1.Service{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "a"}}
+	client := fake.NewSimpleClientset(cached)
+
+	core, logs := observer.New(zap.InfoLevel)
+	handler := newFakeHandler(cached)
+	handler.ktr.Logger = kzap.New(zap.New(core))
+	handler.client = client
+	require.NoError(t, WithDryRun()(handler.ktr))
+	require.NoError(t, OnFinalize("kolibri/cleanup", func(*Kontext, metav1.Object) error { return nil })(&handler.events))
+
+	ktx := handler.newEventContext(context.Background(), &updateEvent{baseEvent: &baseEvent{key: "default/a"}})
+	assert.True(t, ktx.DryRun())
+	assert.False(t, (&Kontext{}).DryRun())
+
+	updated, err := handler.ensureFinalizers(cached)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"kolibri/cleanup"}, updated.GetFinalizers())
+
+	updated, err = ktx.UpdateStatus(cached, func(obj metav1.Object) error {
+		obj.(*corev1.Service).Status.LoadBalancer.Ingress = []corev1.LoadBalancerIngress{{IP: "10.0.0.1"}}
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Len(t, updated.(*corev1.Service).Status.LoadBalancer.Ingress, 1)
+
+	assert.Empty(t, client.Actions(), "Updates must not be sent in dry-run")
+	entries := logs.FilterMessageSnippet("dry-run").All()
+	require.Len(t, entries, 2)
+	assert.Equal(t, "dry-run: update 'default/a'", entries[0].Message)
+	assert.Contains(t, entries[0].ContextMap()["diff"], "object.ObjectMeta.Finalizers")
+	assert.Equal(t, "dry-run: status update 'default/a'", entries[1].Message)
+	assert.Contains(t, entries[1].ContextMap()["diff"], "10.0.0.1")
+}
+
+func TestDryRunPatches(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method == http.MethodGet {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"kind":"Status","apiVersion":"v1","status":"Failure","reason":"NotFound","code":404}`))
+			return
+		}
+
+		assert.Equal(t, []string{"All"}, r.URL.Query()["dryRun"], "Patches must be sent in dry-run")
+		_, _ = w.Write([]byte(`{"kind":"Service","apiVersion":"v1","metadata":{"namespace":"default","name":"a","labels":{"app":"a"}}}`))
+	}))
+	defer server.Close()
+
+	client, err := kubernetes.NewForConfig(&rest.Config{Host: server.URL})
+	require.NoError(t, err)
+
+	handler := newFakeHandler()
+	handler.client = client
+	require.NoError(t, WithDryRun()(handler.ktr))
+	ktx := handler.newEventContext(context.Background(), &updateEvent{baseEvent: &baseEvent{key: "default/a"}})
+
+	obj := &corev1.Service{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "a"}}
+	patched, err := ktx.MergePatch(obj, func(obj metav1.Object) error {
+		obj.SetLabels(map[string]string{"app": "a"})
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"app": "a"}, patched.GetLabels())
+
+	applied, err := ktx.Apply(patched)
+	require.NoError(t, err)
+	assert.Equal(t, "a", applied.GetName())
+}
@@ -0,0 +1,128 @@
+package kolibri
+
+import (
+	"context"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+	"golang.org/x/xerrors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+)
+
+// leaderElection contains the configuration of the leader election between
+// the controller replicas.
+type leaderElection struct {
+	lease     string
+	namespace string
+	identity  string
+
+	// leaseDuration is the time after which a non-renewed lease can be
+	// acquired by another replica; it bounds the leadership handover.
+	leaseDuration time.Duration
+	renewDeadline time.Duration
+	retryPeriod   time.Duration
+
+	// term serializes the leadership terms: a new term only starts once the
+	// previous one is fully stopped.
+	term   sync.Mutex
+	leader int32
+}
+
+// WithLeaderElection elects a leader between the controller replicas through
+// the given coordination Lease. Informers run on all replicas to keep their
+// caches warm, but events are only processed by the leader. When the
+// leadership is lost, the Kontext of in-flight events is cancelled and the
+// remaining events are dropped; the new leader starts by handling all cached
+// objects.
+func WithLeaderElection(lease, namespace string) controllerOption {
+	return func(k *Kontroller) error {
+		if lease == "" || namespace == "" {
+			return xerrors.Errorf("leader election lease name and namespace must be provided")
+		}
+		if k.sharding != nil {
+			return xerrors.Errorf("leader election cannot be used with sharding")
+		}
+
+		hostname, err := os.Hostname()
+		if err != nil {
+			return xerrors.Errorf("failed to generate leader election identity: %w", err)
+		}
+
+		k.election = &leaderElection{
+			lease:         lease,
+			namespace:     namespace,
+			identity:      hostname + "_" + uuid.New().String(),
+			leaseDuration: 15 * time.Second,
+			renewDeadline: 10 * time.Second,
+			retryPeriod:   2 * time.Second,
+		}
+		return nil
+	}
+}
+
+// run takes part in the leader election until the context is done, processing
+// the controller events during the leadership terms.
+func (e *leaderElection) run(ctx context.Context, k *Kontroller) error {
+	lock := &resourcelock.LeaseLock{
+		LeaseMeta:  metav1.ObjectMeta{Name: e.lease, Namespace: e.namespace},
+		Client:     k.kube.CoordinationV1(),
+		LockConfig: resourcelock.ResourceLockConfig{Identity: e.identity},
+	}
+
+	for ctx.Err() == nil {
+		elector, err := leaderelection.NewLeaderElector(leaderelection.LeaderElectionConfig{
+			Name:            k.name,
+			Lock:            lock,
+			LeaseDuration:   e.leaseDuration,
+			RenewDeadline:   e.renewDeadline,
+			RetryPeriod:     e.retryPeriod,
+			ReleaseOnCancel: true,
+			Callbacks: leaderelection.LeaderCallbacks{
+				OnStartedLeading: func(ctx context.Context) {
+					e.term.Lock()
+					defer e.term.Unlock()
+
+					k.Infof("leadership of '%s/%s' acquired by '%s'", e.namespace, e.lease, e.identity)
+					e.setLeader(true)
+					k.process(ctx)
+					e.setLeader(false)
+				},
+				OnStoppedLeading: func() {
+					k.Infof("leadership of '%s/%s' released by '%s'", e.namespace, e.lease, e.identity)
+				},
+			},
+		})
+		if err != nil {
+			return xerrors.Errorf("invalid leader election configuration: %w", err)
+		}
+		elector.Run(ctx)
+	}
+
+	// Wait for the end of the current term
+	e.term.Lock()
+	defer e.term.Unlock()
+	return nil
+}
+
+func (e *leaderElection) setLeader(leader bool) {
+	var value int32
+	if leader {
+		value = 1
+	}
+	atomic.StoreInt32(&e.leader, value)
+}
+
+// IsLeader returns true if the controller currently processes events, which
+// is always the case without leader election nor sharding.
+func (k *Kontroller) IsLeader() bool {
+	if k.sharding != nil {
+		ring, _ := k.sharding.ring.Load().(*hashRing)
+		return ring != nil
+	}
+	return k.election == nil || atomic.LoadInt32(&k.election.leader) == 1
+}
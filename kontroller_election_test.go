@@ -0,0 +1,76 @@
+package kolibri
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/radiofrance/kolibri/kind"
+)
+
+// newElectedController creates a controller, with fast leader election,
+// counting the Service creations it handles.
+func newElectedController(t *testing.T, client kubernetes.Interface, created *int32) *Kontroller {
+	ktr, err := NewController("test", client, WithLeaderElection("kolibri", "default"))
+	require.NoError(t, err)
+	ktr.election.leaseDuration = time.Second
+	ktr.election.renewDeadline = 500 * time.Millisecond
+	ktr.election.retryPeriod = 100 * time.Millisecond
+
+	handler, err := ktr.NewHandler(
+		Kind(&kind.Service{}),
+		OnCreate(func(*Kontext, metav1.Object) error { atomic.AddInt32(created, 1); return nil }),
+	)
+	require.NoError(t, err)
+	require.NoError(t, ktr.Register(handler))
+	return ktr
+}
+
+func TestWithLeaderElection(t *testing.T) {
+	_, err := NewController("test", fake.NewSimpleClientset(), WithLeaderElection("", "default"))
+	assert.Error(t, err)
+
+	ktr, err := NewController("test", fake.NewSimpleClientset())
+	require.NoError(t, err)
+	assert.True(t, ktr.IsLeader(), "Controller without election must always lead")
+}
+
+func TestLeaderElectionHandover(t *testing.T) {
+	client := fake.NewSimpleClientset(
+		&corev1.Service{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "a"}},
+		&corev1.Service{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "b"}},
+	)
+
+	var createdA, createdB int32
+	ktrA := newElectedController(t, client, &createdA)
+	ktrB := newElectedController(t, client, &createdB)
+
+	ctxA, cancelA := context.WithCancel(context.Background())
+	ctxB, cancelB := context.WithCancel(context.Background())
+	defer cancelB()
+
+	doneA := make(chan error)
+	go func() { doneA <- ktrA.Run(ctxA) }()
+	waitFor(t, ktrA.IsLeader)
+	go func() { _ = ktrB.Run(ctxB) }()
+
+	waitFor(t, func() bool { return atomic.LoadInt32(&createdA) == 2 })
+	time.Sleep(2 * time.Second)
+	assert.False(t, ktrB.IsLeader(), "Only one replica must lead")
+	assert.Equal(t, int32(0), atomic.LoadInt32(&createdB), "Followers must not handle events")
+
+	// Stopping the leader releases the lease
+	cancelA()
+	assert.NoError(t, <-doneA)
+	assert.False(t, ktrA.IsLeader())
+	waitFor(t, ktrB.IsLeader, "Follower must take over the leadership")
+	waitFor(t, func() bool { return atomic.LoadInt32(&createdB) == 2 }, "New leader must handle all objects")
+}
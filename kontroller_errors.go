@@ -0,0 +1,158 @@
+package kolibri
+
+import (
+	"fmt"
+	"time"
+
+	"golang.org/x/xerrors"
+	"k8s.io/apimachinery/pkg/api/errors"
+
+	"github.com/radiofrance/kolibri/log"
+)
+
+// maxRetries is the number of times a failed event is retried before being
+// dropped.
+const maxRetries = 10
+
+// ErrorHandler is a function receiving every failure of the handler
+// functions. The Kontext contains the failed event metadata (key, type,
+// attempt, ...).
+type ErrorHandler func(ktx *Kontext, err error)
+
+// WithErrorHandler sets the function receiving every failure of the handler
+// functions, including the retried ones. By default, failures are logged.
+func WithErrorHandler(fnc ErrorHandler) kontrolerOption {
+	return func(ktr *Kontroller) error {
+		if fnc == nil {
+			return xerrors.Errorf("error handler cannot be nil")
+		}
+
+		ktr.errorHandler = fnc
+		return nil
+	}
+}
+
+// handleError reports a failure of the handler functions.
+func (k *Kontroller) handleError(ktx *Kontext, err error) {
+	if k.errorHandler != nil {
+		k.errorHandler(ktx, err)
+		return
+	}
+	ktx.With(log.Error("err", err)).Errorf("failed to handle event")
+}
+
+// ---------------------------------------------------------------------------------------------------------------//
+// Error classification
+
+// retryPolicy defines how a failed event is retried.
+type retryPolicy int
+
+const (
+	// retryRateLimited retries the event through the queue rate limiter,
+	// until maxRetries
+	retryRateLimited retryPolicy = iota
+	// retryAfter retries the event after a given delay, until maxRetries
+	retryAfter
+	// retryNever drops the event
+	retryNever
+	// retryIgnored considers the event as successfully handled
+	retryIgnored
+)
+
+// classifiedError is an error returned with a retry policy.
+type classifiedError struct {
+	err    error
+	policy retryPolicy
+	after  time.Duration
+}
+
+func (e *classifiedError) Error() string {
+	if e.err == nil {
+		return fmt.Sprintf("requeued after %s", e.after)
+	}
+	return e.err.Error()
+}
+func (e *classifiedError) Unwrap() error { return e.err }
+
+// Permanent marks the given error as permanent: the failed event is dropped
+// without being retried. It returns nil if the error is nil.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &classifiedError{err: err, policy: retryNever}
+}
+
+// RequeueAfter retries the failed event after the given delay, instead of
+// the queue rate limiter one; the event is still dropped after too many
+// retries. The error can be nil, to handle the object again later: the event
+// is then considered as successfully handled, is not reported to the
+// ErrorHandler and is requeued indefinitely.
+func RequeueAfter(err error, after time.Duration) error {
+	return &classifiedError{err: err, policy: retryAfter, after: after}
+}
+
+// Ignore considers the event as successfully handled despite the given
+// error, which is only logged at debug level. It returns nil if the error
+// is nil.
+func Ignore(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &classifiedError{err: err, policy: retryIgnored}
+}
+
+// requeued returns the delay of an event requeued with RequeueAfter without
+// error: such events are handled successfully and handled again later.
+func requeued(err error) (time.Duration, bool) {
+	var classified *classifiedError
+	if xerrors.As(err, &classified) && classified.policy == retryAfter && classified.err == nil {
+		return classified.after, true
+	}
+	return 0, false
+}
+
+// classify returns the retry policy of the given error. Errors marked with
+// Permanent, RequeueAfter or Ignore take precedence over the Kubernetes API
+// errors, which are classified as follows:
+//   - NotFound: ignored, the object was deleted meanwhile
+//   - Conflict: retried, the object being read again from the cache
+//   - Forbidden: dropped, retries cannot succeed until permissions change
+//   - TooManyRequests: retried after the delay suggested by the API server,
+//     if any
+func classify(err error) (retryPolicy, time.Duration) {
+	var classified *classifiedError
+	if xerrors.As(err, &classified) {
+		return classified.policy, classified.after
+	}
+
+	var status errors.APIStatus
+	if !xerrors.As(err, &status) {
+		return retryRateLimited, 0
+	}
+	apiErr, _ := status.(error)
+
+	switch {
+	case errors.IsNotFound(apiErr):
+		return retryIgnored, 0
+	case errors.IsForbidden(apiErr):
+		return retryNever, 0
+	case errors.IsTooManyRequests(apiErr):
+		if delay, ok := errors.SuggestsClientDelay(apiErr); ok && delay > 0 {
+			return retryAfter, time.Duration(delay) * time.Second
+		}
+	}
+	return retryRateLimited, 0
+}
+
+// ignore returns nil if the given error must be ignored (see classify).
+func (h *Handler) ignore(container eventContainer, err error) error {
+	if err == nil {
+		return nil
+	}
+	if policy, _ := classify(err); policy == retryIgnored {
+		h.ktr.With(log.Error("err", err), log.String("key", container.Key())).Debugf("ignored handler failure")
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,119 @@
+package kolibri
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/xerrors"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/util/workqueue"
+)
+
+func TestClassify(t *testing.T) {
+	failure := xerrors.New("failure")
+	services := schema.GroupResource{Resource: "services"}
+
+	for _, tc := range []struct {
+		name   string
+		err    error
+		policy retryPolicy
+		after  time.Duration
+	}{
+		{"error", failure, retryRateLimited, 0},
+		{"permanent", Permanent(failure), retryNever, 0},
+		{"wrapped permanent", xerrors.Errorf("sync: %w", Permanent(failure)), retryNever, 0},
+		{"requeue after", RequeueAfter(failure, time.Minute), retryAfter, time.Minute},
+		{"requeue after without error", RequeueAfter(nil, time.Minute), retryAfter, time.Minute},
+		{"ignore", Ignore(failure), retryIgnored, 0},
+		{"not found", xerrors.Errorf("sync: %w", errors.NewNotFound(services, "a")), retryIgnored, 0},
+		{"conflict", errors.NewConflict(services, "a", failure), retryRateLimited, 0},
+		{"forbidden", errors.NewForbidden(services, "a", failure), retryNever, 0},
+		{"too many requests", errors.NewTooManyRequests("slow down", 3), retryAfter, 3 * time.Second},
+		{"too many requests without delay", errors.NewTooManyRequests("slow down", 0), retryRateLimited, 0},
+		{"permanent not found", Permanent(errors.NewNotFound(services, "a")), retryNever, 0},
+	} {
+		policy, after := classify(tc.err)
+		assert.Equal(t, tc.policy, policy, tc.name)
+		assert.Equal(t, tc.after, after, tc.name)
+	}
+
+	assert.Nil(t, Permanent(nil))
+	assert.Nil(t, Ignore(nil))
+	assert.True(t, xerrors.Is(Permanent(failure), failure), "Classified errors must wrap the original error")
+}
+
+func TestErrorHandler(t *testing.T) {
+	handler := newFakeHandler(&corev1.Service{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "a"}})
+
+	var failures []error
+	var keys []string
+	require.NoError(t, WithErrorHandler(func(ktx *Kontext, err error) {
+		failures = append(failures, err)
+		keys = append(keys, ktx.Event.Key)
+	})(handler.ktr))
+	assert.Error(t, WithErrorHandler(nil)(handler.ktr))
+
+	var result error
+	handler.events.updateFuncs = append(handler.events.updateFuncs, func(*Kontext, metav1.Object) error { return result })
+	process := func() {
+		handler.add(&updateEvent{baseEvent: &baseEvent{key: "default/a"}})
+		require.True(t, handler.processNextWorkItem(context.Background(), handler.queue))
+	}
+
+	result = Ignore(xerrors.New("ignored"))
+	process()
+	assert.Empty(t, failures, "Ignored errors must not be reported")
+	assert.Equal(t, 0, handler.queue.Len())
+
+	result = Permanent(xerrors.New("permanent"))
+	process()
+	assert.Len(t, failures, 1)
+	assert.Equal(t, []string{"default/a"}, keys)
+	assert.Equal(t, 0, handler.queue.Len(), "Permanent failures must not be retried")
+
+	result = RequeueAfter(xerrors.New("later"), 50*time.Millisecond)
+	process()
+	assert.Len(t, failures, 2)
+	assert.Equal(t, 0, handler.queue.Len())
+	waitFor(t, func() bool { return handler.queue.Len() == 1 }, "Event must be requeued after the given delay")
+	event, _ := handler.queue.Get()
+	handler.queue.Done(event)
+
+	result = RequeueAfter(nil, 50*time.Millisecond)
+	process()
+	assert.Len(t, failures, 2, "Events requeued without error must not be reported")
+	waitFor(t, func() bool { return handler.queue.Len() == 1 }, "Event must be requeued after the given delay")
+}
+
+// immediateQueue requeues delayed events immediately.
+type immediateQueue struct {
+	workqueue.RateLimitingInterface
+}
+
+func (q immediateQueue) AddAfter(item interface{}, _ time.Duration) { q.Add(item) }
+
+func TestDelayedRetries(t *testing.T) {
+	handler := newFakeHandler(&corev1.Service{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "a"}})
+	queue := immediateQueue{handler.queue}
+
+	var attempts []int
+	handler.events.updateFuncs = append(handler.events.updateFuncs, func(ktx *Kontext, _ metav1.Object) error {
+		attempts = append(attempts, ktx.Event.Attempt)
+		return errors.NewTooManyRequests("slow down", 3)
+	})
+	require.NoError(t, WithErrorHandler(func(*Kontext, error) {})(handler.ktr))
+
+	queue.Add(&updateEvent{baseEvent: &baseEvent{key: "default/a"}})
+	for i := 0; i <= maxRetries && queue.Len() > 0; i++ {
+		require.True(t, handler.processNextWorkItem(context.Background(), queue))
+	}
+	assert.Zero(t, queue.Len(), "Delayed retries must be dropped after too many retries")
+	require.Len(t, attempts, maxRetries+1)
+	assert.Equal(t, maxRetries+1, attempts[maxRetries], "Delayed retries must be counted as attempts")
+}
@@ -0,0 +1,222 @@
+package kolibri
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/xerrors"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+)
+
+// health contains the configuration of the controller health and readiness
+// checks.
+type health struct {
+	// stuckThreshold is the time after which a worker handling the same
+	// event is considered as stuck.
+	stuckThreshold time.Duration
+	// watchThreshold is the time after which failing informers list and
+	// watch calls make the controller unhealthy.
+	watchThreshold time.Duration
+
+	healthChecks    []namedCheck
+	readinessChecks []namedCheck
+}
+
+// namedCheck is a named health or readiness check.
+type namedCheck struct {
+	name  string
+	check func() error
+}
+
+func newHealth() *health {
+	return &health{
+		stuckThreshold: 10 * time.Minute,
+		watchThreshold: 5 * time.Minute,
+	}
+}
+
+// WithHealthThresholds sets the time after which a worker handling the same
+// event is considered as stuck (10 minutes by default) and the time after
+// which failing informers make the controller unhealthy (5 minutes by
+// default).
+func WithHealthThresholds(stuckWorker, failingWatch time.Duration) controllerOption {
+	return func(k *Kontroller) error {
+		if stuckWorker <= 0 || failingWatch <= 0 {
+			return xerrors.Errorf("health thresholds must be positive")
+		}
+		k.health.stuckThreshold = stuckWorker
+		k.health.watchThreshold = failingWatch
+		return nil
+	}
+}
+
+// WithHealthCheck adds a user-defined check to the controller health.
+func WithHealthCheck(name string, check func() error) controllerOption {
+	return func(k *Kontroller) error {
+		if name == "" || check == nil {
+			return xerrors.Errorf("health check name and function must be provided")
+		}
+		k.health.healthChecks = append(k.health.healthChecks, namedCheck{name, check})
+		return nil
+	}
+}
+
+// WithReadinessCheck adds a user-defined check to the controller readiness.
+func WithReadinessCheck(name string, check func() error) controllerOption {
+	return func(k *Kontroller) error {
+		if name == "" || check == nil {
+			return xerrors.Errorf("readiness check name and function must be provided")
+		}
+		k.health.readinessChecks = append(k.health.readinessChecks, namedCheck{name, check})
+		return nil
+	}
+}
+
+// Healthy returns an error if the controller is not healthy: a worker is
+// stuck on an event, informers fail to list or watch the API server, or a
+// user-defined check (see WithHealthCheck) fails.
+func (k *Kontroller) Healthy() error { return runChecks(k.healthChecks()) }
+
+// Ready returns an error if the controller is not ready: the cache of a
+// registered handler is not synced yet, or a user-defined check (see
+// WithReadinessCheck) fails.
+func (k *Kontroller) Ready() error { return runChecks(k.readinessChecks()) }
+
+func (k *Kontroller) healthChecks() []namedCheck {
+	return append([]namedCheck{
+		{"workers", k.checkWorkers},
+		{"informers", k.checkWatches},
+	}, k.health.healthChecks...)
+}
+func (k *Kontroller) readinessChecks() []namedCheck {
+	return append([]namedCheck{
+		{"caches", k.checkCaches},
+	}, k.health.readinessChecks...)
+}
+
+// checkWorkers fails if a worker handles the same event for too long.
+func (k *Kontroller) checkWorkers() error {
+	for _, handler := range k.registered() {
+		event, since := handler.oldestInflight()
+		if event != nil && time.Since(since) > k.health.stuckThreshold {
+			return xerrors.Errorf("handler '%s' handles %s event of '%s' since %s", handler.name, eventType(event), event.Key(), since.Format(time.RFC3339))
+		}
+	}
+	return nil
+}
+
+// checkWatches fails if informers fail to list or watch for too long.
+func (k *Kontroller) checkWatches() error {
+	since, failing := reflectorFailures.failingSince(time.Now())
+	if failing && time.Since(since) > k.health.watchThreshold {
+		return xerrors.Errorf("informers fail to list or watch since %s", since.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// checkCaches fails if the cache of a registered handler is not synced.
+func (k *Kontroller) checkCaches() error {
+	for _, handler := range k.registered() {
+		if !handler.synced() {
+			return xerrors.Errorf("handler '%s' cache is not synced", handler.name)
+		}
+	}
+	return nil
+}
+
+// runChecks runs all given checks, returning an error describing all
+// failing ones.
+func runChecks(checks []namedCheck) error {
+	var failures []string
+	for _, c := range checks {
+		if err := c.check(); err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %s", c.name, err))
+		}
+	}
+
+	if len(failures) > 0 {
+		return xerrors.New(strings.Join(failures, ", "))
+	}
+	return nil
+}
+
+// checksHandler serves the result of all given checks, with the given status
+// code on failure.
+func checksHandler(checks func() []namedCheck, failureCode int) http.HandlerFunc {
+	return func(w http.ResponseWriter, _ *http.Request) {
+		var body bytes.Buffer
+		code := http.StatusOK
+
+		for _, c := range checks() {
+			if err := c.check(); err != nil {
+				code = failureCode
+				fmt.Fprintf(&body, "[-]%s failed: %s\n", c.name, err)
+			} else {
+				fmt.Fprintf(&body, "[+]%s ok\n", c.name)
+			}
+		}
+
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.WriteHeader(code)
+		_, _ = body.WriteTo(w)
+	}
+}
+
+// ---------------------------------------------------------------------------------------------------------------//
+// Informers failures
+
+// failureResetWindow is the time without failure after which informers are
+// considered as recovered.
+const failureResetWindow = 30 * time.Second
+
+// reflectorFailures tracks the informers list and watch failures, reported
+// by client-go through utilruntime.HandleError.
+var (
+	reflectorFailures     = &failureTracker{}
+	reflectorFailuresOnce sync.Once
+)
+
+// trackReflectorFailures registers the informers failures tracker in the
+// client-go error handlers.
+func trackReflectorFailures() {
+	reflectorFailuresOnce.Do(func() {
+		utilruntime.ErrorHandlers = append(utilruntime.ErrorHandlers, func(err error) {
+			if msg := err.Error(); strings.Contains(msg, "Failed to list") || strings.Contains(msg, "Failed to watch") {
+				reflectorFailures.record(time.Now())
+			}
+		})
+	})
+}
+
+// failureTracker tracks the beginning of successive failures.
+type failureTracker struct {
+	mu    sync.Mutex
+	first time.Time
+	last  time.Time
+}
+
+func (t *failureTracker) record(now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if now.Sub(t.last) > failureResetWindow {
+		t.first = now
+	}
+	t.last = now
+}
+
+// failingSince returns the time of the first of the current successive
+// failures, if any.
+func (t *failureTracker) failingSince(now time.Time) (time.Time, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.last.IsZero() || now.Sub(t.last) > failureResetWindow {
+		return time.Time{}, false
+	}
+	return t.first, true
+}
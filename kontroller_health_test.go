@@ -0,0 +1,91 @@
+package kolibri
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/xerrors"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestHealthEndpoints(t *testing.T) {
+	var notReady error
+	ktr, err := NewController("test", fake.NewSimpleClientset(),
+		WithHTTPServer(":0"),
+		WithHealthThresholds(time.Minute, time.Minute),
+		WithReadinessCheck("user", func() error { return notReady }),
+	)
+	require.NoError(t, err)
+
+	handler := newFakeHandler()
+	handler.owner = ktr
+	require.NoError(t, ktr.Register(handler))
+
+	get := func(path string) (int, string) {
+		rec := httptest.NewRecorder()
+		ktr.server.mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec.Code, rec.Body.String()
+	}
+
+	code, body := get("/readyz")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "[+]caches ok\n[+]user ok\n", body)
+
+	notReady = xerrors.New("not ready")
+	code, body = get("/readyz")
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, "[+]caches ok\n[-]user failed: not ready\n", body)
+
+	code, _ = get("/healthz")
+	assert.Equal(t, http.StatusOK, code)
+
+	// Worker stuck on an event
+	event := &updateEvent{baseEvent: &baseEvent{key: "default/a"}}
+	handler.inflight[event] = time.Now().Add(-2 * time.Minute)
+	code, body = get("/healthz")
+	assert.Equal(t, http.StatusInternalServerError, code)
+	assert.Contains(t, body, "[-]workers failed: handler 'service' handles update event of 'default/a'")
+	assert.Error(t, ktr.Healthy())
+}
+
+func TestWithHealthOptions(t *testing.T) {
+	tests := []struct {
+		name string
+		opt  ControllerOption
+	}{
+		{"HTTPServer", WithHTTPServer("")},
+		{"Thresholds", WithHealthThresholds(0, time.Minute)},
+		{"HealthCheck", WithHealthCheck("", func() error { return nil })},
+		{"ReadinessCheck", WithReadinessCheck("ready", nil)},
+	}
+
+	for _, tt := range tests {
+		_, err := NewController("test", fake.NewSimpleClientset(), tt.opt)
+		assert.Error(t, err, "Invalid option %s must fail.", tt.name)
+	}
+}
+
+func TestFailureTracker(t *testing.T) {
+	tracker := &failureTracker{}
+	now := time.Now()
+
+	_, failing := tracker.failingSince(now)
+	assert.False(t, failing)
+
+	tracker.record(now.Add(-20 * time.Second))
+	tracker.record(now.Add(-time.Second))
+	since, failing := tracker.failingSince(now)
+	assert.True(t, failing)
+	assert.Equal(t, now.Add(-20*time.Second), since)
+
+	// Recovered after the reset window
+	_, failing = tracker.failingSince(now.Add(failureResetWindow + time.Second))
+	assert.False(t, failing)
+	tracker.record(now.Add(time.Hour))
+	since, _ = tracker.failingSince(now.Add(time.Hour))
+	assert.Equal(t, now.Add(time.Hour), since)
+}
@@ -0,0 +1,174 @@
+package kolibri
+
+import (
+	"context"
+
+	"golang.org/x/xerrors"
+
+	"github.com/radiofrance/kolibri/log"
+)
+
+// HookFunc is a function called at a given step of the controller or
+// handler lifecycle. Hooks are called in the following order by
+// Kontroller.Run:
+//   - OnStart hooks of the controller, then of each handler before its
+//     informers are started
+//   - OnCacheSynced hooks of each handler once its caches are synced, then of
+//     the controller once all caches are synced
+//   - OnStartedLeading hooks of the controller, then of each handler, when
+//     the controller starts processing events (at once without leader
+//     election nor sharding)
+//   - OnStoppedLeading hooks of each handler once its workers are drained,
+//     then of the controller, when the controller stops processing events
+//   - OnStop hooks of each handler, then of the controller, at shutdown
+//
+// An error returned by an OnStart or OnCacheSynced hook aborts Run; other
+// errors are logged, except for OnStop ones which are returned by Run.
+// OnStop hooks are given a non-cancelled Kontext.
+type HookFunc func(ktx *Kontext) error
+
+// hookStage is a step of the controller or handler lifecycle.
+type hookStage string
+
+const (
+	hookStart          hookStage = "start"
+	hookCacheSynced    hookStage = "cache synced"
+	hookStartedLeading hookStage = "started leading"
+	hookStoppedLeading hookStage = "stopped leading"
+	hookStop           hookStage = "stop"
+)
+
+// hooks contains the lifecycle hooks, by stage.
+type hooks map[hookStage][]HookFunc
+
+// run calls all hooks of the given stage, in order, until the first error.
+func (h hooks) run(ktx *Kontext, stage hookStage) error {
+	for _, fnc := range h[stage] {
+		if err := fnc(ktx); err != nil {
+			return xerrors.Errorf("%s hook failed: %w", stage, err)
+		}
+	}
+	return nil
+}
+
+// runAndLog calls all hooks of the given stage, logging the error if any.
+func (h hooks) runAndLog(ktx *Kontext, stage hookStage) {
+	if err := h.run(ktx, stage); err != nil {
+		ktx.With(log.Error("err", err)).Errorf("failed to run %s hooks", stage)
+	}
+}
+
+// hookOption registers a lifecycle hook, on the controller when given to
+// NewController or on the handler when given to NewHandler.
+type hookOption struct {
+	stage hookStage
+	fnc   HookFunc
+}
+
+func (o hookOption) apply(ctx *handlerBuildContext) error {
+	if o.fnc == nil {
+		return xerrors.Errorf("%s hook cannot be nil", o.stage)
+	}
+	ctx.hooks[o.stage] = append(ctx.hooks[o.stage], o.fnc)
+	return nil
+}
+func (o hookOption) applyController(k *Kontroller) error {
+	if o.fnc == nil {
+		return xerrors.Errorf("%s hook cannot be nil", o.stage)
+	}
+	k.hooks[o.stage] = append(k.hooks[o.stage], o.fnc)
+	return nil
+}
+
+// OnStart registers a function called before the informers start.
+func OnStart(fnc HookFunc) hookOption { return hookOption{stage: hookStart, fnc: fnc} }
+
+// OnCacheSynced registers a function called once the informers caches are
+// synced.
+func OnCacheSynced(fnc HookFunc) hookOption { return hookOption{stage: hookCacheSynced, fnc: fnc} }
+
+// OnStartedLeading registers a function called when the controller starts
+// processing events, like when it acquires the leadership.
+func OnStartedLeading(fnc HookFunc) hookOption {
+	return hookOption{stage: hookStartedLeading, fnc: fnc}
+}
+
+// OnStoppedLeading registers a function called when the controller stops
+// processing events, once all workers are drained.
+func OnStoppedLeading(fnc HookFunc) hookOption {
+	return hookOption{stage: hookStoppedLeading, fnc: fnc}
+}
+
+// OnStop registers a function called at shutdown, once all workers are
+// drained.
+func OnStop(fnc HookFunc) hookOption { return hookOption{stage: hookStop, fnc: fnc} }
+
+// ---------------------------------------------------------------------------------------------------------------//
+// Lifecycle
+
+// run starts all handlers and processes their events until the context is
+// done, calling the lifecycle hooks.
+func (k *Kontroller) run(ctx context.Context) error {
+	handlers, err := k.registry.begin(ctx)
+	if err != nil {
+		return err
+	}
+	// Handlers registered from now on are started by Register
+	defer func() {
+		if err != nil {
+			k.registry.end()
+		}
+	}()
+	if err = checkDependencies(handlers); err != nil {
+		return err
+	}
+
+	ktx := k.newContext(ctx, "hooks")
+	if err = k.hooks.run(ktx, hookStart); err != nil {
+		return err
+	}
+	running := make([]*runningHandler, len(handlers))
+	for i, handler := range handlers {
+		if running[i], err = k.startInformers(handler); err != nil {
+			return err
+		}
+	}
+
+	for i, handler := range handlers {
+		if err = k.syncHandler(handler, running[i]); err != nil {
+			return err
+		}
+	}
+	if err = k.hooks.run(ktx, hookCacheSynced); err != nil {
+		return err
+	}
+
+	var processErr error
+	switch {
+	case k.sharding != nil:
+		// Caches are kept warm on all replicas, but events are only
+		// processed by the shard owning their key
+		processErr = k.sharding.run(ctx, k)
+	case k.election != nil:
+		// Caches are kept warm on all replicas, but events are only
+		// processed by the leader
+		processErr = k.election.run(ctx, k)
+	default:
+		k.process(ctx)
+	}
+
+	var stopErr error
+	for _, handler := range k.registry.end() {
+		if err := handler.hooks.run(handler.ktr.newContext(context.Background(), "hooks"), hookStop); err != nil && stopErr == nil {
+			stopErr = xerrors.Errorf("handler '%s': %w", handler.name, err)
+		}
+	}
+	if err := k.hooks.run(k.newContext(context.Background(), "hooks"), hookStop); err != nil && stopErr == nil {
+		stopErr = err
+	}
+
+	if processErr != nil {
+		return processErr
+	}
+	return stopErr
+}
@@ -0,0 +1,100 @@
+package kolibri
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/xerrors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/radiofrance/kolibri/kind"
+)
+
+// hookRecorder records the called hooks.
+type hookRecorder struct {
+	mu    sync.Mutex
+	calls []string
+}
+
+func (r *hookRecorder) hook(name string, err error) HookFunc {
+	return func(*Kontext) error {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.calls = append(r.calls, name)
+		return err
+	}
+}
+func (r *hookRecorder) called() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string{}, r.calls...)
+}
+
+func TestLifecycleHooks(t *testing.T) {
+	recorder := &hookRecorder{}
+	ktr, err := NewController("test", fake.NewSimpleClientset(),
+		OnStart(recorder.hook("controller start", nil)),
+		OnCacheSynced(recorder.hook("controller synced", nil)),
+		OnStartedLeading(recorder.hook("controller started leading", nil)),
+		OnStoppedLeading(recorder.hook("controller stopped leading", nil)),
+		OnStop(recorder.hook("controller stop", nil)),
+	)
+	require.NoError(t, err)
+
+	handler, err := ktr.NewHandler(
+		Kind(&kind.Service{}),
+		OnCreate(func(*Kontext, metav1.Object) error { return nil }),
+		OnStart(recorder.hook("handler start", nil)),
+		OnCacheSynced(recorder.hook("handler synced", nil)),
+		OnStartedLeading(recorder.hook("handler started leading", nil)),
+		OnStoppedLeading(recorder.hook("handler stopped leading", nil)),
+		OnStop(recorder.hook("handler stop", xerrors.New("failure"))),
+	)
+	require.NoError(t, err)
+	require.NoError(t, ktr.Register(handler))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- ktr.Run(ctx) }()
+	waitFor(t, func() bool { return len(recorder.called()) == 6 })
+	cancel()
+
+	assert.Error(t, <-done, "OnStop errors must be returned")
+	assert.Equal(t, []string{
+		"controller start",
+		"handler start",
+		"handler synced",
+		"controller synced",
+		"controller started leading",
+		"handler started leading",
+		"handler stopped leading",
+		"controller stopped leading",
+		"handler stop",
+		"controller stop",
+	}, recorder.called())
+}
+
+func TestStartHookAbortsRun(t *testing.T) {
+	recorder := &hookRecorder{}
+	ktr, err := NewController("test", fake.NewSimpleClientset(), OnStart(recorder.hook("controller start", nil)))
+	require.NoError(t, err)
+
+	handler, err := ktr.NewHandler(
+		Kind(&kind.Service{}),
+		OnCreate(func(*Kontext, metav1.Object) error { return nil }),
+		OnStart(recorder.hook("handler start", xerrors.New("failure"))),
+		OnCacheSynced(recorder.hook("handler synced", nil)),
+	)
+	require.NoError(t, err)
+	require.NoError(t, ktr.Register(handler))
+
+	assert.Error(t, ktr.Run(context.Background()))
+	assert.Equal(t, []string{"controller start", "handler start"}, recorder.called())
+
+	_, err = NewController("test", fake.NewSimpleClientset(), OnStop(nil))
+	assert.Error(t, err)
+}
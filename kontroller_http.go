@@ -0,0 +1,49 @@
+package kolibri
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"golang.org/x/xerrors"
+)
+
+// httpServer serves the controller HTTP endpoints.
+type httpServer struct {
+	addr string
+	mux  *http.ServeMux
+}
+
+// WithHTTPServer serves the controller HTTP endpoints on the given address
+// (like `:8080`), while the controller runs:
+//   - /healthz fails if the controller is not healthy (see Kontroller.Healthy)
+//   - /readyz fails if the controller is not ready (see Kontroller.Ready)
+func WithHTTPServer(addr string) controllerOption {
+	return func(k *Kontroller) error {
+		if addr == "" {
+			return xerrors.Errorf("http server address cannot be empty")
+		}
+
+		k.server = &httpServer{addr: addr, mux: http.NewServeMux()}
+		k.server.mux.HandleFunc("/healthz", checksHandler(k.healthChecks, http.StatusInternalServerError))
+		k.server.mux.HandleFunc("/readyz", checksHandler(k.readinessChecks, http.StatusServiceUnavailable))
+		return nil
+	}
+}
+
+// run serves the endpoints until the context is done.
+func (s *httpServer) run(ctx context.Context) error {
+	server := &http.Server{Addr: s.addr, Handler: s.mux}
+
+	errc := make(chan error, 1)
+	go func() { errc <- server.ListenAndServe() }()
+
+	select {
+	case err := <-errc:
+		return xerrors.Errorf("http server failed: %w", err)
+	case <-ctx.Done():
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		return server.Shutdown(ctx)
+	}
+}
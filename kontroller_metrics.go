@@ -0,0 +1,355 @@
+package kolibri
+
+import (
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/xerrors"
+	"k8s.io/client-go/util/workqueue"
+)
+
+// metrics contains the prometheus collectors of a controller.
+type metrics struct {
+	depth          *prometheus.GaugeVec
+	adds           *prometheus.CounterVec
+	retries        *prometheus.CounterVec
+	latency        *prometheus.HistogramVec
+	workDuration   *prometheus.HistogramVec
+	unfinished     *prometheus.GaugeVec
+	longestRunning *prometheus.GaugeVec
+
+	handled  *prometheus.CounterVec
+	dropped  *prometheus.CounterVec
+	panicked *prometheus.CounterVec
+	ignored  *prometheus.CounterVec
+	synced   *prometheus.GaugeVec
+}
+
+// WithMetrics exposes the controller metrics through the given prometheus
+// registerer:
+//   - work queues depth, adds, retries, queue latency and work duration
+//   - handled events, by event type and result (success or failure)
+//   - events dropped after too many retries or a permanent failure, by event
+//     type
+//   - panics recovered while handling events, by event type
+//   - timed out calls ignoring cancellation, by event type
+//   - informers sync status
+//
+// All metrics are labelled with the controller name, the handler kind and
+// the handler name. Several controllers can share the same registerer. The
+// series of unregistered handlers are deleted.
+//
+// Work queues metrics are provided through the client-go workqueue metrics
+// provider, which can only be set once per process.
+func WithMetrics(registerer prometheus.Registerer) controllerOption {
+	return func(k *Kontroller) error {
+		if registerer == nil {
+			return xerrors.Errorf("metrics registerer cannot be nil")
+		}
+
+		m, err := newMetrics(registerer)
+		if err != nil {
+			return err
+		}
+		k.metrics = m
+
+		setProviderOnce.Do(func() { workqueue.SetProvider(queueMetricsProvider{}) })
+		return nil
+	}
+}
+
+func newMetrics(registerer prometheus.Registerer) (*metrics, error) {
+	b := &metricsBuilder{registerer: registerer, labels: []string{"controller", "kind", "handler"}}
+
+	m := &metrics{
+		depth:          b.gauge("workqueue", "depth", "Current depth of the handler work queue."),
+		adds:           b.counter("workqueue", "adds_total", "Total number of events added to the handler work queue."),
+		retries:        b.counter("workqueue", "retries_total", "Total number of retries handled by the handler work queue."),
+		latency:        b.histogram("workqueue", "queue_duration_seconds", "How long in seconds an event stays in the handler work queue before being handled."),
+		workDuration:   b.histogram("workqueue", "work_duration_seconds", "How long in seconds handling an event takes."),
+		unfinished:     b.gauge("workqueue", "unfinished_work_seconds", "How many seconds of work has been done that is in progress."),
+		longestRunning: b.gauge("workqueue", "longest_running_processor_seconds", "How many seconds has the longest running event been handled."),
+
+		handled:  b.counter("handler", "events_total", "Total number of events handled, by event type and result.", "event", "result"),
+		dropped:  b.counter("handler", "dropped_events_total", "Total number of events dropped after too many retries or a permanent failure, by event type.", "event"),
+		panicked: b.counter("handler", "panics_total", "Total number of panics recovered while handling events, by event type.", "event"),
+		ignored:  b.counter("handler", "ignored_cancellations_total", "Total number of timed out calls abandoned because they ignored cancellation, by event type.", "event"),
+		synced:   b.gauge("informer", "synced", "Whether the handler informers caches are synced (1) or not (0)."),
+	}
+	if b.err != nil {
+		return nil, xerrors.Errorf("failed to register metrics: %w", b.err)
+	}
+	return m, nil
+}
+
+// forHandler returns the collectors of the given handler.
+func (m *metrics) forHandler(controller, kind, handler string) *handlerMetrics {
+	labels := prometheus.Labels{"controller": controller, "kind": kind, "handler": handler}
+
+	return &handlerMetrics{
+		depth:          m.depth.With(labels),
+		adds:           m.adds.With(labels),
+		retries:        m.retries.With(labels),
+		latency:        m.latency.With(labels),
+		workDuration:   m.workDuration.With(labels),
+		unfinished:     m.unfinished.With(labels),
+		longestRunning: m.longestRunning.With(labels),
+
+		handled:  m.handled.MustCurryWith(labels),
+		dropped:  m.dropped.MustCurryWith(labels),
+		panicked: m.panicked.MustCurryWith(labels),
+		ignored:  m.ignored.MustCurryWith(labels),
+		synced:   m.synced.With(labels),
+	}
+}
+
+// metricsBuilder creates and registers the collectors, keeping the first
+// registration error. Already registered collectors are reused.
+type metricsBuilder struct {
+	registerer prometheus.Registerer
+	labels     []string
+	err        error
+}
+
+func (b *metricsBuilder) register(collector prometheus.Collector) prometheus.Collector {
+	err := b.registerer.Register(collector)
+	if registered, ok := err.(prometheus.AlreadyRegisteredError); ok {
+		return registered.ExistingCollector
+	}
+	if err != nil && b.err == nil {
+		b.err = err
+	}
+	return collector
+}
+func (b *metricsBuilder) gauge(subsystem, name, help string, labels ...string) *prometheus.GaugeVec {
+	opts := prometheus.GaugeOpts{Namespace: "kolibri", Subsystem: subsystem, Name: name, Help: help}
+	return b.register(prometheus.NewGaugeVec(opts, append(b.labels, labels...))).(*prometheus.GaugeVec)
+}
+func (b *metricsBuilder) counter(subsystem, name, help string, labels ...string) *prometheus.CounterVec {
+	opts := prometheus.CounterOpts{Namespace: "kolibri", Subsystem: subsystem, Name: name, Help: help}
+	return b.register(prometheus.NewCounterVec(opts, append(b.labels, labels...))).(*prometheus.CounterVec)
+}
+func (b *metricsBuilder) histogram(subsystem, name, help string, labels ...string) *prometheus.HistogramVec {
+	opts := prometheus.HistogramOpts{Namespace: "kolibri", Subsystem: subsystem, Name: name, Help: help, Buckets: prometheus.ExponentialBuckets(0.001, 4, 10)}
+	return b.register(prometheus.NewHistogramVec(opts, append(b.labels, labels...))).(*prometheus.HistogramVec)
+}
+
+// deleteHandler deletes the series of the given handler.
+func (m *metrics) deleteHandler(controller, kind, handler string) {
+	labels := prometheus.Labels{"controller": controller, "kind": kind, "handler": handler}
+	with := func(name, value string) prometheus.Labels {
+		l := prometheus.Labels{name: value}
+		for k, v := range labels {
+			l[k] = v
+		}
+		return l
+	}
+
+	for _, vec := range []interface{ Delete(prometheus.Labels) bool }{
+		m.depth, m.adds, m.retries, m.latency, m.workDuration, m.unfinished, m.longestRunning, m.synced,
+	} {
+		vec.Delete(labels)
+	}
+	events := []EventType{EventCreate, EventUpdate, EventDelete, EventReplace, EventGeneric, EventScheduled, "unknown"}
+	for _, event := range events {
+		l := with("event", string(event))
+		m.dropped.Delete(l)
+		m.panicked.Delete(l)
+		m.ignored.Delete(l)
+		for _, result := range []string{"success", "failure"} {
+			r := with("event", string(event))
+			r["result"] = result
+			m.handled.Delete(r)
+		}
+	}
+}
+
+// ---------------------------------------------------------------------------------------------------------------//
+// Handler metrics
+
+// handlerMetrics contains the collectors of a single handler. A nil
+// handlerMetrics collects nothing.
+type handlerMetrics struct {
+	depth          prometheus.Gauge
+	adds           prometheus.Counter
+	retries        prometheus.Counter
+	latency        prometheus.Observer
+	workDuration   prometheus.Observer
+	unfinished     prometheus.Gauge
+	longestRunning prometheus.Gauge
+
+	handled  *prometheus.CounterVec
+	dropped  *prometheus.CounterVec
+	panicked *prometheus.CounterVec
+	ignored  *prometheus.CounterVec
+	synced   prometheus.Gauge
+}
+
+// handledEvent counts an handled event, by its result.
+func (m *handlerMetrics) handledEvent(container eventContainer, err error) {
+	if m == nil {
+		return
+	}
+	result := "success"
+	if err != nil {
+		result = "failure"
+	}
+	m.handled.WithLabelValues(string(eventType(container)), result).Inc()
+}
+
+// droppedEvent counts an event dropped after too many retries or a
+// permanent failure.
+func (m *handlerMetrics) droppedEvent(container eventContainer) {
+	if m == nil {
+		return
+	}
+	m.dropped.WithLabelValues(string(eventType(container))).Inc()
+}
+
+// recoveredPanic counts a panic recovered while handling an event.
+func (m *handlerMetrics) recoveredPanic(container eventContainer) {
+	if m == nil {
+		return
+	}
+	m.panicked.WithLabelValues(string(eventType(container))).Inc()
+}
+
+// ignoredCancellation counts a timed out call abandoned because it ignored
+// cancellation.
+func (m *handlerMetrics) ignoredCancellation(container eventContainer) {
+	if m == nil {
+		return
+	}
+	m.ignored.WithLabelValues(string(eventType(container))).Inc()
+}
+
+// setSynced updates the informers sync status.
+func (m *handlerMetrics) setSynced(synced bool) {
+	if m == nil {
+		return
+	}
+	if synced {
+		m.synced.Set(1)
+	} else {
+		m.synced.Set(0)
+	}
+}
+
+// ---------------------------------------------------------------------------------------------------------------//
+// Work queues metrics
+
+var (
+	setProviderOnce sync.Once
+
+	// queuesMetrics contains the handler metrics of each work queue, by name.
+	queuesMetrics   = map[string]*handlerMetrics{}
+	queuesMetricsMu sync.RWMutex
+)
+
+// registerQueueMetrics binds the given metrics to the work queues with the
+// given name.
+func registerQueueMetrics(name string, m *handlerMetrics) {
+	queuesMetricsMu.Lock()
+	defer queuesMetricsMu.Unlock()
+	queuesMetrics[name] = m
+}
+
+// unregisterQueueMetrics unbinds the metrics of the work queues with the
+// given name.
+func unregisterQueueMetrics(name string) {
+	queuesMetricsMu.Lock()
+	defer queuesMetricsMu.Unlock()
+	delete(queuesMetrics, name)
+}
+
+// dropMetrics deletes the metrics of a removed handler.
+func (h *Handler) dropMetrics() {
+	if h.metrics == nil {
+		return
+	}
+	unregisterQueueMetrics(h.queueName)
+	h.ktr.metrics.deleteHandler(h.ktr.name, h.kind.Name(), h.name)
+}
+
+func queueMetrics(name string) *handlerMetrics {
+	queuesMetricsMu.RLock()
+	defer queuesMetricsMu.RUnlock()
+	return queuesMetrics[name]
+}
+
+// queueMetricsProvider provides the handler metrics to its work queue. Queues
+// created by other components are not measured.
+type queueMetricsProvider struct{}
+
+func (queueMetricsProvider) NewDepthMetric(name string) workqueue.GaugeMetric {
+	if m := queueMetrics(name); m != nil {
+		return m.depth
+	}
+	return noopMetric{}
+}
+func (queueMetricsProvider) NewAddsMetric(name string) workqueue.CounterMetric {
+	if m := queueMetrics(name); m != nil {
+		return m.adds
+	}
+	return noopMetric{}
+}
+func (queueMetricsProvider) NewLatencyMetric(name string) workqueue.HistogramMetric {
+	if m := queueMetrics(name); m != nil {
+		return m.latency
+	}
+	return noopMetric{}
+}
+func (queueMetricsProvider) NewWorkDurationMetric(name string) workqueue.HistogramMetric {
+	if m := queueMetrics(name); m != nil {
+		return m.workDuration
+	}
+	return noopMetric{}
+}
+func (queueMetricsProvider) NewUnfinishedWorkSecondsMetric(name string) workqueue.SettableGaugeMetric {
+	if m := queueMetrics(name); m != nil {
+		return m.unfinished
+	}
+	return noopMetric{}
+}
+func (queueMetricsProvider) NewLongestRunningProcessorSecondsMetric(name string) workqueue.SettableGaugeMetric {
+	if m := queueMetrics(name); m != nil {
+		return m.longestRunning
+	}
+	return noopMetric{}
+}
+func (queueMetricsProvider) NewRetriesMetric(name string) workqueue.CounterMetric {
+	if m := queueMetrics(name); m != nil {
+		return m.retries
+	}
+	return noopMetric{}
+}
+
+// Deprecated metrics are not provided.
+func (queueMetricsProvider) NewDeprecatedDepthMetric(string) workqueue.GaugeMetric {
+	return noopMetric{}
+}
+func (queueMetricsProvider) NewDeprecatedAddsMetric(string) workqueue.CounterMetric {
+	return noopMetric{}
+}
+func (queueMetricsProvider) NewDeprecatedLatencyMetric(string) workqueue.SummaryMetric {
+	return noopMetric{}
+}
+func (queueMetricsProvider) NewDeprecatedWorkDurationMetric(string) workqueue.SummaryMetric {
+	return noopMetric{}
+}
+func (queueMetricsProvider) NewDeprecatedUnfinishedWorkSecondsMetric(string) workqueue.SettableGaugeMetric {
+	return noopMetric{}
+}
+func (queueMetricsProvider) NewDeprecatedLongestRunningProcessorMicrosecondsMetric(string) workqueue.SettableGaugeMetric {
+	return noopMetric{}
+}
+func (queueMetricsProvider) NewDeprecatedRetriesMetric(string) workqueue.CounterMetric {
+	return noopMetric{}
+}
+
+type noopMetric struct{}
+
+func (noopMetric) Inc()            {}
+func (noopMetric) Dec()            {}
+func (noopMetric) Set(float64)     {}
+func (noopMetric) Observe(float64) {}
@@ -0,0 +1,93 @@
+package kolibri
+
+import (
+	"context"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/xerrors"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/radiofrance/kolibri/kind"
+)
+
+func TestWithMetrics(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	client := fake.NewSimpleClientset(
+		&corev1.Service{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "a"}},
+		&corev1.Service{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "b"}},
+	)
+
+	_, err := NewController("test", client, WithMetrics(nil))
+	assert.Error(t, err)
+
+	// Several controllers can share the same registry
+	_, err = NewController("other", client, WithMetrics(registry))
+	require.NoError(t, err)
+	ktr, err := NewController("test", client, WithMetrics(registry))
+	require.NoError(t, err)
+
+	handler, err := ktr.NewHandler(
+		Kind(&kind.Service{}),
+		WithName("services"),
+		OnCreate(func(_ *Kontext, obj metav1.Object) error {
+			if obj.GetName() == "b" {
+				return xerrors.New("failure")
+			}
+			return nil
+		}),
+	)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() { _ = handler.Run(ctx) }()
+
+	labels := prometheus.Labels{"controller": "test", "kind": "Service", "handler": "services"}
+	handled := ktr.metrics.handled.MustCurryWith(labels)
+	waitFor(t, func() bool { return testutil.ToFloat64(handled.WithLabelValues("create", "success")) == 1 })
+	waitFor(t, func() bool { return testutil.ToFloat64(handled.WithLabelValues("create", "failure")) >= 1 })
+
+	assert.Equal(t, float64(1), testutil.ToFloat64(ktr.metrics.synced.With(labels)))
+	assert.True(t, testutil.ToFloat64(ktr.metrics.adds.With(labels)) >= 2)
+	waitFor(t, func() bool { return testutil.ToFloat64(ktr.metrics.retries.With(labels)) >= 1 })
+}
+
+func TestUnregisterMetrics(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	client := fake.NewSimpleClientset(&corev1.Service{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "a"}})
+	ktr, err := NewController("test", client, WithMetrics(registry))
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() { _ = ktr.Run(ctx) }()
+
+	handler, err := ktr.NewHandler(Kind(&kind.Service{}), WithName("services"), OnCreate(func(*Kontext, metav1.Object) error { return nil }))
+	require.NoError(t, err)
+	require.NoError(t, ktr.Register(handler))
+
+	labels := prometheus.Labels{"controller": "test", "kind": "Service", "handler": "services"}
+	waitFor(t, func() bool {
+		return testutil.ToFloat64(ktr.metrics.handled.MustCurryWith(labels).WithLabelValues("create", "success")) == 1
+	})
+	require.NotNil(t, queueMetrics(handler.queueName))
+
+	require.NoError(t, ktr.Unregister(handler))
+	assert.Nil(t, queueMetrics(handler.queueName), "Work queues of removed handlers must not be measured")
+
+	families, err := registry.Gather()
+	require.NoError(t, err)
+	for _, family := range families {
+		for _, metric := range family.GetMetric() {
+			for _, label := range metric.GetLabel() {
+				assert.False(t, label.GetName() == "handler" && label.GetValue() == "services", "Series of removed handlers must be deleted: %s", family.GetName())
+			}
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package kolibri
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/radiofrance/kolibri/log"
+)
+
+// PanicError is the error returned when an handler function panics.
+type PanicError struct {
+	// Value is the value given to panic.
+	Value interface{}
+	// Stack is the stacktrace of the panicking goroutine.
+	Stack string
+}
+
+func (e *PanicError) Error() string { return fmt.Sprintf("handler panicked: %v", e.Value) }
+
+// WithCrashOnPanic lets panics of the handler functions crash the
+// controller. By default, panics are recovered, logged with their stacktrace
+// and handled as errors, through the retry policy.
+func WithCrashOnPanic() controllerOption {
+	return func(k *Kontroller) error {
+		k.crashOnPanic = true
+		return nil
+	}
+}
+
+// safeSyncHandler handles the given event, recovering the panics of the
+// handler functions unless the controller crashes on panic.
+func (h *Handler) safeSyncHandler(ctx context.Context, container eventContainer) (err error) {
+	if h.ktr.crashOnPanic {
+		return h.syncHandler(ctx, container)
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			stack := log.Stack("stack")
+			if p, isPanic := r.(*PanicError); isPanic {
+				// Raised by an handler function call with timeout
+				stack.String = p.Stack
+				err = p
+			} else {
+				err = &PanicError{Value: r, Stack: stack.String}
+			}
+			h.metrics.recoveredPanic(container)
+			h.ktr.With(log.Error("err", err), stack).Errorf("handler '%s' panicked while handling %s event of '%s'", h.name, eventType(container), container.Key())
+		}
+	}()
+	return h.syncHandler(ctx, container)
+}
@@ -0,0 +1,58 @@
+package kolibri
+
+import (
+	"context"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/radiofrance/kolibri/kind"
+)
+
+func TestRecoverPanic(t *testing.T) {
+	handler := newFakeHandler(&corev1.Service{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "a"}})
+	m, err := newMetrics(prometheus.NewRegistry())
+	require.NoError(t, err)
+	handler.metrics = m.forHandler("test", "Service", "service")
+
+	var panics int
+	handler.events.updateFuncs = append(handler.events.updateFuncs, func(*Kontext, metav1.Object) error {
+		panics++
+		panic("boom")
+	})
+	event := &updateEvent{baseEvent: &baseEvent{key: "default/a"}}
+
+	err = handler.safeSyncHandler(context.Background(), event)
+	require.IsType(t, &PanicError{}, err)
+	assert.Equal(t, "handler panicked: boom", err.Error())
+	assert.Contains(t, err.(*PanicError).Stack, "TestRecoverPanic")
+	assert.Equal(t, float64(1), testutil.ToFloat64(handler.metrics.panicked.WithLabelValues("update")))
+
+	// Panics follow the retry policy
+	handler.add(event)
+	assert.True(t, handler.processNextWorkItem(context.Background(), handler.queue))
+	assert.Equal(t, 2, panics)
+	assert.Equal(t, 1, handler.queue.NumRequeues(event))
+
+	handler.ktr.crashOnPanic = true
+	assert.Panics(t, func() { _ = handler.safeSyncHandler(context.Background(), event) })
+}
+
+func TestRunNotSynced(t *testing.T) {
+	ktr, err := NewController("test", fake.NewSimpleClientset(), WithCrashOnPanic())
+	require.NoError(t, err)
+	assert.True(t, ktr.crashOnPanic)
+
+	handler, err := ktr.NewHandler(Kind(&kind.Service{}), OnCreate(func(*Kontext, metav1.Object) error { return nil }))
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.Error(t, handler.Run(ctx), "Handler stopped before sync must fail without panicking")
+}
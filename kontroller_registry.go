@@ -0,0 +1,328 @@
+package kolibri
+
+import (
+	"context"
+	"sync"
+
+	"golang.org/x/xerrors"
+
+	"github.com/radiofrance/kolibri/log"
+)
+
+// registryState is the state of the controller, regarding handlers
+// registration.
+type registryState int
+
+const (
+	registryIdle registryState = iota
+	registryRunning
+	registryStopped
+)
+
+// registry contains the handlers registered in a controller and, while the
+// controller runs, their running state.
+type registry struct {
+	mu       sync.Mutex
+	handlers []*Handler
+	state    registryState
+
+	// ctx is the context given to Kontroller.Run, while running
+	ctx     context.Context
+	running map[*Handler]*runningHandler
+	// term is the current processing term, nil while the controller does
+	// not process events
+	term *term
+}
+
+// runningHandler is the running state of an handler.
+type runningHandler struct {
+	// ctx is cancelled when the handler is removed, stopping its informers
+	// and workers
+	ctx    context.Context
+	cancel context.CancelFunc
+	// ready is true once the handler caches are synced
+	ready bool
+	// processing is closed once the handler stops processing the events of
+	// the current term, nil if it does not process events
+	processing chan struct{}
+}
+
+// term is a period during which the controller processes events (see
+// Kontroller.process).
+type term struct {
+	ctx      context.Context
+	handlers sync.WaitGroup
+}
+
+func newRegistry() *registry {
+	return &registry{running: map[*Handler]*runningHandler{}}
+}
+
+// Register adds handlers to the controller. Handlers must have been built by
+// the controller (see NewHandler) and have unique names; two handlers of the
+// same kind cannot watch all namespaces and a single one.
+//
+// Handlers registered while the controller runs are started at once and
+// process events once their caches are synced. Handlers cannot be registered
+// once the controller is stopped.
+func (k *Kontroller) Register(handlers ...*Handler) error {
+	r := k.registry
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.state == registryStopped {
+		return xerrors.Errorf("cannot register handlers in a stopped controller")
+	}
+
+	registered := append([]*Handler{}, r.handlers...)
+	for _, handler := range handlers {
+		if err := k.validate(handler, registered); err != nil {
+			return err
+		}
+		registered = append(registered, handler)
+	}
+	if r.state == registryRunning {
+		if err := checkDependencies(registered); err != nil {
+			return err
+		}
+	}
+
+	r.handlers = registered
+	if r.state == registryRunning {
+		for _, handler := range handlers {
+			go k.startHandler(handler)
+		}
+	}
+	return nil
+}
+
+// validate returns an error if the given handler cannot be registered with
+// the given ones.
+func (k *Kontroller) validate(handler *Handler, registered []*Handler) error {
+	switch {
+	case handler == nil:
+		return xerrors.Errorf("handler cannot be nil")
+	case handler.name == "":
+		return xerrors.Errorf("handler name cannot be empty")
+	case handler.owner != k:
+		return xerrors.Errorf("handler '%s' was not built by controller '%s'", handler.name, k.name)
+	case handler.removed:
+		return xerrors.Errorf("handler '%s' was removed and cannot be registered again", handler.name)
+	}
+
+	for _, other := range registered {
+		if other == handler {
+			return xerrors.Errorf("handler '%s' is already registered", handler.name)
+		}
+		if other.name == handler.name {
+			return xerrors.Errorf("an handler named '%s' is already registered", handler.name)
+		}
+		if conflictingScopes(handler, other) {
+			return xerrors.Errorf("handlers '%s' and '%s' have conflicting scopes for kind %s/%s", other.name, handler.name, handler.kind.APIVersion(), handler.kind.Name())
+		}
+	}
+	return nil
+}
+
+// conflictingScopes returns true if both handlers watch the same kind in the
+// same cluster, one in all namespaces and the other in a single one.
+func conflictingScopes(a, b *Handler) bool {
+	sameKind := a.Cluster() == b.Cluster() && a.kind.APIVersion() == b.kind.APIVersion() && a.kind.Name() == b.kind.Name()
+	return sameKind && a.namespace != b.namespace && (a.namespace == "" || b.namespace == "")
+}
+
+// Unregister removes handlers from the controller. While the controller
+// runs, their workers are drained (see OnStoppedLeading) and their informers
+// are stopped before their OnStop hooks are called. Removed handlers cannot
+// be registered again.
+func (k *Kontroller) Unregister(handlers ...*Handler) error {
+	r := k.registry
+	r.mu.Lock()
+
+	remaining := append([]*Handler{}, r.handlers...)
+	for _, handler := range handlers {
+		i := indexOf(remaining, handler)
+		if i < 0 {
+			r.mu.Unlock()
+			return xerrors.Errorf("handler is not registered")
+		}
+		remaining = append(remaining[:i], remaining[i+1:]...)
+	}
+	if err := checkDependencies(remaining); err != nil {
+		r.mu.Unlock()
+		return err
+	}
+	r.handlers = remaining
+
+	stopped := map[*Handler]*runningHandler{}
+	for _, handler := range handlers {
+		handler.removed = true
+		if running, exists := r.running[handler]; exists {
+			delete(r.running, handler)
+			stopped[handler] = running
+		}
+	}
+	r.mu.Unlock()
+
+	for _, handler := range handlers {
+		if running, exists := stopped[handler]; exists {
+			running.cancel()
+			if running.processing != nil {
+				<-running.processing
+			}
+			handler.hooks.runAndLog(handler.ktr.newContext(context.Background(), "hooks"), hookStop)
+		}
+		handler.dropMetrics()
+	}
+	return nil
+}
+
+func indexOf(handlers []*Handler, handler *Handler) int {
+	for i, h := range handlers {
+		if h == handler {
+			return i
+		}
+	}
+	return -1
+}
+
+// registered returns the registered handlers.
+func (k *Kontroller) registered() []*Handler {
+	if k.registry == nil {
+		return nil
+	}
+
+	k.registry.mu.Lock()
+	defer k.registry.mu.Unlock()
+	return append([]*Handler{}, k.registry.handlers...)
+}
+
+// ---------------------------------------------------------------------------------------------------------------//
+// Running handlers
+
+// begin marks the controller as running, returning the registered handlers.
+func (r *registry) begin(ctx context.Context) ([]*Handler, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.state != registryIdle {
+		return nil, xerrors.Errorf("controller cannot be run twice")
+	}
+	r.state = registryRunning
+	r.ctx = ctx
+	return append([]*Handler{}, r.handlers...), nil
+}
+
+// end marks the controller as stopped, returning the registered handlers.
+func (r *registry) end() []*Handler {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.state = registryStopped
+	r.running = map[*Handler]*runningHandler{}
+	return append([]*Handler{}, r.handlers...)
+}
+
+// startInformers calls the OnStart hooks of the given handler and starts its
+// informers.
+func (k *Kontroller) startInformers(handler *Handler) (*runningHandler, error) {
+	r := k.registry
+	r.mu.Lock()
+	ctx := r.ctx
+	r.mu.Unlock()
+
+	if err := handler.hooks.run(handler.ktr.newContext(ctx, "hooks"), hookStart); err != nil {
+		return nil, xerrors.Errorf("handler '%s': %w", handler.name, err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.state != registryRunning || indexOf(r.handlers, handler) < 0 {
+		return nil, xerrors.Errorf("handler '%s' removed before being started", handler.name)
+	}
+
+	running := &runningHandler{}
+	running.ctx, running.cancel = context.WithCancel(r.ctx)
+	r.running[handler] = running
+	handler.startInformers(running.ctx.Done())
+	return running, nil
+}
+
+// syncHandler waits for the caches of the given handler, calls its
+// OnCacheSynced hooks and marks it as ready to process events.
+func (k *Kontroller) syncHandler(handler *Handler, running *runningHandler) error {
+	if err := handler.waitForCacheSync(running.ctx.Done()); err != nil {
+		return err
+	}
+	if err := handler.hooks.run(handler.ktr.newContext(running.ctx, "hooks"), hookCacheSynced); err != nil {
+		return xerrors.Errorf("handler '%s': %w", handler.name, err)
+	}
+
+	r := k.registry
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	running.ready = true
+	if r.term != nil && r.running[handler] == running {
+		r.process(handler, running, r.term)
+	}
+	return nil
+}
+
+// startHandler starts an handler registered while the controller runs.
+func (k *Kontroller) startHandler(handler *Handler) {
+	running, err := k.startInformers(handler)
+	if err == nil {
+		err = k.syncHandler(handler, running)
+	}
+	if err != nil && (running == nil || running.ctx.Err() == nil) {
+		k.With(log.Error("err", err)).Errorf("failed to start handler '%s'", handler.name)
+	}
+}
+
+// startTerm starts processing the events of all ready handlers, and of the
+// handlers becoming ready until the term ends.
+func (r *registry) startTerm(ctx context.Context) *term {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	t := &term{ctx: ctx}
+	r.term = t
+	for handler, running := range r.running {
+		if running.ready {
+			r.process(handler, running, t)
+		}
+	}
+	return t
+}
+
+// endTerm stops starting handlers in the current term.
+func (r *registry) endTerm() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.term = nil
+}
+
+// process processes the events of the given handler during the given term,
+// or until the handler is removed. The registry lock must be held.
+func (r *registry) process(handler *Handler, running *runningHandler, t *term) {
+	ctx, cancel := context.WithCancel(t.ctx)
+	processing := make(chan struct{})
+	running.processing = processing
+
+	t.handlers.Add(1)
+	go func() {
+		defer t.handlers.Done()
+		defer close(processing)
+		defer cancel()
+
+		go func() {
+			select {
+			case <-running.ctx.Done():
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
+		handler.process(ctx)
+	}()
+}
@@ -0,0 +1,101 @@
+package kolibri
+
+import (
+	"context"
+	"runtime"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/radiofrance/kolibri/kind"
+)
+
+func TestRegisterValidation(t *testing.T) {
+	ktr, err := NewController("test", fake.NewSimpleClientset())
+	require.NoError(t, err)
+	other, err := NewController("other", fake.NewSimpleClientset())
+	require.NoError(t, err)
+
+	onCreate := OnCreate(func(*Kontext, metav1.Object) error { return nil })
+	newHandler := func(k *Kontroller, opts ...Option) *Handler {
+		handler, err := k.NewHandler(append(opts, onCreate)...)
+		require.NoError(t, err)
+		return handler
+	}
+
+	services := newHandler(ktr, Kind(&kind.Service{}))
+	require.NoError(t, ktr.Register(services))
+
+	assert.Error(t, ktr.Register(nil), "Nil handlers must be rejected")
+	assert.Error(t, ktr.Register(services), "Duplicated handlers must be rejected")
+	assert.Error(t, ktr.Register(newHandler(ktr, Kind(&kind.Service{}))), "Duplicated names must be rejected")
+	assert.Error(t, ktr.Register(newHandler(other, Kind(&kind.Ingress{}))), "Handlers of other controllers must be rejected")
+	assert.Error(t, ktr.Register(newHandler(ktr, Kind(&kind.Service{}), WithName("scoped"), OnNamespace("default"))), "Conflicting scopes must be rejected")
+
+	scoped := newHandler(ktr, Kind(&kind.Ingress{}), OnNamespace("default"))
+	assert.Error(t, ktr.Register(scoped, newHandler(ktr, Kind(&kind.Ingress{}), WithName("cluster"))), "Conflicting scopes must be rejected")
+	assert.Equal(t, []*Handler{services}, ktr.registered(), "Rejected registrations must be atomic")
+	require.NoError(t, ktr.Register(scoped, newHandler(ktr, Kind(&kind.Ingress{}), WithName("kube-system"), OnNamespace("kube-system"))))
+
+	require.NoError(t, ktr.Unregister(scoped))
+	assert.Error(t, ktr.Unregister(scoped), "Unregistered handlers cannot be removed")
+	assert.Error(t, ktr.Register(scoped), "Removed handlers cannot be registered again")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_ = ktr.Run(ctx) // stopped before the caches are synced
+	assert.Error(t, ktr.Run(ctx), "Controllers cannot be run twice")
+	assert.Error(t, ktr.Register(newHandler(ktr, Kind(&kind.Service{}), WithName("late"))), "Stopped controllers must reject registrations")
+}
+
+func TestDynamicRegistration(t *testing.T) {
+	client := fake.NewSimpleClientset(&corev1.Service{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "a"}})
+	ktr, err := NewController("test", client)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- ktr.Run(ctx) }()
+
+	var handled, stopped int32
+	handler, err := ktr.NewHandler(
+		Kind(&kind.Service{}),
+		OnCreate(func(*Kontext, metav1.Object) error { atomic.AddInt32(&handled, 1); return nil }),
+		OnStop(func(*Kontext) error { atomic.AddInt32(&stopped, 1); return nil }),
+	)
+	require.NoError(t, err)
+	require.NoError(t, ktr.Register(handler))
+	waitFor(t, func() bool { return atomic.LoadInt32(&handled) == 1 }, "Handlers registered while running must process events")
+
+	require.NoError(t, ktr.Unregister(handler))
+	assert.Nil(t, handler.workqueue(), "Removed handlers must stop processing events")
+	assert.Equal(t, int32(1), atomic.LoadInt32(&stopped), "Removed handlers must be stopped")
+
+	cancel()
+	assert.NoError(t, <-done)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&stopped), "Removed handlers must not be stopped twice")
+}
+
+func TestRegistrationCycles(t *testing.T) {
+	ktr, err := NewController("test", fake.NewSimpleClientset())
+	require.NoError(t, err)
+
+	cycle := func() {
+		handler, err := ktr.NewHandler(Kind(&kind.Service{}), OnCreate(func(*Kontext, metav1.Object) error { return nil }))
+		require.NoError(t, err)
+		require.NoError(t, ktr.Register(handler))
+		require.NoError(t, ktr.Unregister(handler))
+	}
+
+	cycle()
+	goroutines := runtime.NumGoroutine()
+	for i := 0; i < 20; i++ {
+		cycle()
+	}
+	waitFor(t, func() bool { return runtime.NumGoroutine() <= goroutines }, "Handlers must not leak goroutines")
+}
@@ -0,0 +1,280 @@
+package kolibri
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"reflect"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+	"golang.org/x/xerrors"
+	coordinationv1 "k8s.io/api/coordination/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+
+	"github.com/radiofrance/kolibri/log"
+)
+
+// shardGroupLabel is the label identifying the Leases of a sharding group.
+const shardGroupLabel = "kolibri.radiofrance.com/shard-group"
+
+// sharding contains the configuration of the keys sharding between the
+// controller replicas.
+type sharding struct {
+	group     string
+	namespace string
+	identity  string
+	lease     string
+
+	// leaseDuration is the time after which a non-renewed replica Lease is
+	// considered as expired; it bounds the rebalancing when a replica
+	// crashes.
+	leaseDuration time.Duration
+	// renewDeadline is the time after which a replica unable to renew its
+	// Lease or to list the group members stops processing events.
+	renewDeadline time.Duration
+	retryPeriod   time.Duration
+
+	// ring is the current hash ring (*hashRing), nil while the replica
+	// does not process events.
+	ring atomic.Value
+}
+
+// WithSharding shards the objects keys between the controller replicas,
+// each replica only processing the keys it owns. Replicas coordinate their
+// membership through a coordination Lease per replica, labelled with the
+// given group, in the given namespace. Keys are distributed through
+// consistent hashing of their `namespace/name`, so only the keys of a
+// joining or leaving replica are moved.
+//
+// On each membership change, the Kontext of in-flight events is cancelled
+// and each replica starts by handling all cached objects it owns; a key can
+// be handled by two replicas until all of them have seen the change (about
+// the retry period). It cannot be used with WithLeaderElection.
+func WithSharding(group, namespace string) controllerOption {
+	return func(k *Kontroller) error {
+		if group == "" || namespace == "" {
+			return xerrors.Errorf("sharding group and namespace must be provided")
+		}
+		if k.election != nil {
+			return xerrors.Errorf("sharding cannot be used with leader election")
+		}
+
+		hostname, err := os.Hostname()
+		if err != nil {
+			return xerrors.Errorf("failed to generate sharding identity: %w", err)
+		}
+
+		id := uuid.New().String()
+		k.sharding = &sharding{
+			group:         group,
+			namespace:     namespace,
+			identity:      hostname + "_" + id,
+			lease:         group + "-" + id,
+			leaseDuration: 15 * time.Second,
+			renewDeadline: 10 * time.Second,
+			retryPeriod:   2 * time.Second,
+		}
+		return nil
+	}
+}
+
+// run takes part in the sharding group until the context is done. A new
+// processing term is started on each membership change.
+func (s *sharding) run(ctx context.Context, k *Kontroller) error {
+	var members []string
+	var lastSync time.Time
+
+	var term sync.WaitGroup
+	stopTerm := func() {}
+	defer func() {
+		stopTerm()
+		term.Wait()
+		s.ring.Store((*hashRing)(nil))
+		s.release(k)
+	}()
+
+	for {
+		current, err := s.sync(k)
+		if err != nil {
+			k.With(log.Error("err", err)).Errorf("failed to sync members of sharding group '%s/%s'", s.namespace, s.group)
+			current = members
+			if time.Since(lastSync) > s.renewDeadline {
+				current = nil
+			}
+		} else {
+			lastSync = time.Now()
+		}
+
+		if !reflect.DeepEqual(current, members) {
+			stopTerm()
+			term.Wait()
+
+			members = current
+			if len(members) == 0 {
+				s.ring.Store((*hashRing)(nil))
+				k.Warnf("replica '%s' left sharding group '%s/%s'", s.identity, s.namespace, s.group)
+			} else {
+				s.ring.Store(newHashRing(members))
+				k.Infof("sharding group '%s/%s' rebalanced between %d replicas", s.namespace, s.group, len(members))
+
+				termCtx, cancel := context.WithCancel(ctx)
+				stopTerm = cancel
+				term.Add(1)
+				go func() {
+					defer term.Done()
+					k.process(termCtx)
+				}()
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(s.retryPeriod):
+		}
+	}
+}
+
+// sync renews the replica Lease and returns the identities of the live
+// members of the group, including this replica.
+func (s *sharding) sync(k *Kontroller) ([]string, error) {
+	leases := k.kube.CoordinationV1().Leases(s.namespace)
+	now := metav1.NowMicro()
+
+	lease, err := leases.Get(s.lease, metav1.GetOptions{})
+	switch {
+	case errors.IsNotFound(err):
+		duration := int32(s.leaseDuration / time.Second)
+		lease = &coordinationv1.Lease{
+			ObjectMeta: metav1.ObjectMeta{Name: s.lease, Namespace: s.namespace, Labels: map[string]string{shardGroupLabel: s.group}},
+			Spec: coordinationv1.LeaseSpec{
+				HolderIdentity:       &s.identity,
+				LeaseDurationSeconds: &duration,
+				AcquireTime:          &now,
+				RenewTime:            &now,
+			},
+		}
+		if _, err = leases.Create(lease); err != nil {
+			return nil, xerrors.Errorf("failed to create lease '%s': %w", s.lease, err)
+		}
+	case err != nil:
+		return nil, xerrors.Errorf("failed to get lease '%s': %w", s.lease, err)
+	default:
+		lease.Spec.RenewTime = &now
+		if _, err = leases.Update(lease); err != nil {
+			return nil, xerrors.Errorf("failed to renew lease '%s': %w", s.lease, err)
+		}
+	}
+
+	selector := labels.SelectorFromSet(labels.Set{shardGroupLabel: s.group})
+	list, err := leases.List(metav1.ListOptions{LabelSelector: selector.String()})
+	if err != nil {
+		return nil, xerrors.Errorf("failed to list leases: %w", err)
+	}
+
+	var members []string
+	for _, lease := range list.Items {
+		spec := lease.Spec
+		if spec.HolderIdentity == nil || spec.RenewTime == nil || spec.LeaseDurationSeconds == nil {
+			continue
+		}
+		expiry := spec.RenewTime.Add(time.Duration(*spec.LeaseDurationSeconds) * time.Second)
+		if *spec.HolderIdentity == s.identity || now.Time.Before(expiry) {
+			members = append(members, *spec.HolderIdentity)
+		}
+	}
+	sort.Strings(members)
+	return members, nil
+}
+
+// release deletes the replica Lease, so other replicas rebalance without
+// waiting for its expiration.
+func (s *sharding) release(k *Kontroller) {
+	err := k.kube.CoordinationV1().Leases(s.namespace).Delete(s.lease, &metav1.DeleteOptions{})
+	if err != nil && !errors.IsNotFound(err) {
+		k.With(log.Error("err", err)).Errorf("failed to release lease '%s'", s.lease)
+	}
+}
+
+// owns returns true if the given key belongs to this replica.
+func (s *sharding) owns(key string) bool {
+	ring, _ := s.ring.Load().(*hashRing)
+	return ring != nil && ring.owner(key) == s.identity
+}
+
+// owns returns true if the controller must handle the given key, which is
+// always the case without sharding.
+func (k *Kontroller) owns(key string) bool {
+	return k.sharding == nil || k.sharding.owns(key)
+}
+
+// ---------------------------------------------------------------------------------------------------------------//
+// Consistent hashing
+
+// ringReplicas is the number of points of each member on the hash ring,
+// smoothing the keys distribution.
+const ringReplicas = 128
+
+// hashRing distributes keys between members through consistent hashing.
+type hashRing struct {
+	points []uint32
+	owners map[uint32]string
+}
+
+func newHashRing(members []string) *hashRing {
+	ring := &hashRing{owners: map[uint32]string{}}
+	for _, member := range members {
+		for i := 0; i < ringReplicas; i++ {
+			point := hash(fmt.Sprintf("%s#%d", member, i))
+			if _, exists := ring.owners[point]; exists {
+				continue
+			}
+			ring.owners[point] = member
+			ring.points = append(ring.points, point)
+		}
+	}
+	sort.Slice(ring.points, func(i, j int) bool { return ring.points[i] < ring.points[j] })
+	return ring
+}
+
+// owner returns the member owning the given key: the first member point
+// following the key hash on the ring.
+func (r *hashRing) owner(key string) string {
+	if len(r.points) == 0 {
+		return ""
+	}
+
+	h := hash(key)
+	i := sort.Search(len(r.points), func(i int) bool { return r.points[i] >= h })
+	if i == len(r.points) {
+		i = 0
+	}
+	return r.owners[r.points[i]]
+}
+
+// hash returns the FNV-1a hash of the given string, followed by the murmur3
+// finalizer: FNV alone barely mixes its last bytes, so the points of a member
+// (`member#i`) would be clustered on the ring.
+func hash(s string) uint32 {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(s))
+	return fmix32(h.Sum32())
+}
+
+// fmix32 is the murmur3 finalizer, making each bit of the hash depend on all
+// the input bits.
+func fmix32(h uint32) uint32 {
+	h ^= h >> 16
+	h *= 0x85ebca6b
+	h ^= h >> 13
+	h *= 0xc2b2ae35
+	h ^= h >> 16
+	return h
+}
@@ -0,0 +1,187 @@
+package kolibri
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/radiofrance/kolibri/kind"
+)
+
+func TestHashRing(t *testing.T) {
+	var keys []string
+	for i := 0; i < 1000; i++ {
+		keys = append(keys, fmt.Sprintf("default/svc-%d", i))
+	}
+
+	ring := newHashRing([]string{"a", "b", "c"})
+	owners := map[string]string{}
+	counts := map[string]int{}
+	for _, key := range keys {
+		owners[key] = ring.owner(key)
+		counts[owners[key]]++
+	}
+	for _, member := range []string{"a", "b", "c"} {
+		assert.True(t, counts[member] > 200, "Member %s owns only %d keys.", member, counts[member])
+	}
+
+	// Only the keys of the leaving member are moved
+	ring = newHashRing([]string{"a", "b"})
+	for _, key := range keys {
+		if owners[key] != "c" {
+			assert.Equal(t, owners[key], ring.owner(key), "Key %s must not move.", key)
+		}
+	}
+
+	assert.Equal(t, "", newHashRing(nil).owner("default/a"))
+}
+
+func TestHashRingDistribution(t *testing.T) {
+	for _, members := range [][]string{
+		{"a", "b"},
+		{"a", "b", "c"},
+		{"a", "b", "c", "d", "e"},
+		{"kolibri-0_7c1f1b8e", "kolibri-1_0d52a3c4", "kolibri-2_e9b6f017"},
+	} {
+		ring := newHashRing(members)
+		counts := map[string]int{}
+		for i := 0; i < 3000; i++ {
+			counts[ring.owner(fmt.Sprintf("ns-%d/obj-%d", i%7, i))]++
+		}
+
+		fair := 3000 / len(members)
+		for _, member := range members {
+			assert.InDelta(t, fair, counts[member], float64(fair)/4, "Member %s of %v owns %d keys.", member, members, counts[member])
+		}
+	}
+}
+
+func TestWithSharding(t *testing.T) {
+	_, err := NewController("test", fake.NewSimpleClientset(), WithSharding("", "default"))
+	assert.Error(t, err)
+	_, err = NewController("test", fake.NewSimpleClientset(), WithSharding("kolibri", "default"), WithLeaderElection("kolibri", "default"))
+	assert.Error(t, err)
+	_, err = NewController("test", fake.NewSimpleClientset(), WithLeaderElection("kolibri", "default"), WithSharding("kolibri", "default"))
+	assert.Error(t, err)
+}
+
+// shardedController is a controller, with fast sharding, recording the
+// Service creations it handles.
+type shardedController struct {
+	*Kontroller
+
+	mu      sync.Mutex
+	handled map[string]bool
+}
+
+func newShardedController(t *testing.T, client kubernetes.Interface) *shardedController {
+	ktr, err := NewController("test", client, WithSharding("kolibri", "default"))
+	require.NoError(t, err)
+	ktr.sharding.leaseDuration = time.Second
+	ktr.sharding.renewDeadline = 500 * time.Millisecond
+	ktr.sharding.retryPeriod = 100 * time.Millisecond
+
+	c := &shardedController{Kontroller: ktr, handled: map[string]bool{}}
+	handler, err := ktr.NewHandler(
+		Kind(&kind.Service{}),
+		OnCreate(func(_ *Kontext, obj metav1.Object) error {
+			c.mu.Lock()
+			defer c.mu.Unlock()
+			c.handled[obj.GetNamespace()+"/"+obj.GetName()] = true
+			return nil
+		}),
+	)
+	require.NoError(t, err)
+	require.NoError(t, ktr.Register(handler))
+	return c
+}
+
+// members returns the number of members in the controller hash ring.
+func (c *shardedController) members() int {
+	ring, _ := c.sharding.ring.Load().(*hashRing)
+	if ring == nil {
+		return 0
+	}
+	members := map[string]bool{}
+	for _, member := range ring.owners {
+		members[member] = true
+	}
+	return len(members)
+}
+
+// handledOnly returns true if the controller handled exactly the given keys.
+func (c *shardedController) handledOnly(keys []string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(c.handled) != len(keys) {
+		return false
+	}
+	for _, key := range keys {
+		if !c.handled[key] {
+			return false
+		}
+	}
+	return true
+}
+
+func (c *shardedController) reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.handled = map[string]bool{}
+}
+
+func TestShardingRebalance(t *testing.T) {
+	var objs []runtime.Object
+	var keys []string
+	for i := 0; i < 20; i++ {
+		objs = append(objs, &corev1.Service{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: fmt.Sprintf("svc-%d", i)}})
+		keys = append(keys, fmt.Sprintf("default/svc-%d", i))
+	}
+	client := fake.NewSimpleClientset(objs...)
+
+	ktrA := newShardedController(t, client)
+	ktrB := newShardedController(t, client)
+
+	ctxA, cancelA := context.WithCancel(context.Background())
+	defer cancelA()
+	ctxB, cancelB := context.WithCancel(context.Background())
+
+	go func() { _ = ktrA.Run(ctxA) }()
+	waitFor(t, func() bool { return ktrA.handledOnly(keys) }, "Single replica must handle all keys")
+
+	// Keys are shared when a replica joins
+	ktrA.reset()
+	doneB := make(chan error)
+	go func() { doneB <- ktrB.Run(ctxB) }()
+	waitFor(t, func() bool { return ktrA.members() == 2 && ktrB.members() == 2 }, "Replicas must see each other")
+
+	var keysA, keysB []string
+	for _, key := range keys {
+		if ktrA.sharding.owns(key) {
+			keysA = append(keysA, key)
+		} else {
+			keysB = append(keysB, key)
+		}
+		assert.NotEqual(t, ktrA.sharding.owns(key), ktrB.sharding.owns(key), "Key %s must have a single owner.", key)
+	}
+	waitFor(t, func() bool { return ktrA.handledOnly(keysA) }, "Replica A must handle its keys")
+	waitFor(t, func() bool { return ktrB.handledOnly(keysB) }, "Replica B must handle its keys")
+
+	// Keys are taken over when a replica leaves
+	ktrA.reset()
+	cancelB()
+	assert.NoError(t, <-doneB)
+	assert.False(t, ktrB.IsLeader())
+	waitFor(t, func() bool { return ktrA.handledOnly(keys) }, "Remaining replica must handle all keys")
+}
@@ -0,0 +1,154 @@
+package kolibri
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	extensionsv1beta1 "k8s.io/api/extensions/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+
+	"github.com/radiofrance/kolibri/kind"
+	logfake "github.com/radiofrance/kolibri/log/fake"
+)
+
+func TestControllerDefaults(t *testing.T) {
+	var limiters int
+	rateLimiter := func() workqueue.RateLimiter {
+		limiters++
+		return workqueue.DefaultItemBasedRateLimiter()
+	}
+
+	ktr, err := NewController("test", fake.NewSimpleClientset(),
+		WithLogger(logfake.New()),
+		WithResync(time.Minute),
+		WithWorkers(3),
+		WithRateLimiter(rateLimiter),
+	)
+	require.NoError(t, err)
+
+	onCreate := OnCreate(func(*Kontext, metav1.Object) error { return nil })
+	inherited, err := ktr.NewHandler(Kind(&kind.Service{}), onCreate)
+	require.NoError(t, err)
+	assert.Equal(t, time.Minute, inherited.resync)
+	assert.Equal(t, 3, inherited.workers)
+	inherited.ktr.rateLimiter()
+	assert.Equal(t, 1, limiters)
+
+	overridden, err := ktr.NewHandler(Kind(&kind.Service{}), onCreate, WithResync(0), WithWorkers(1))
+	require.NoError(t, err)
+	assert.Equal(t, time.Duration(0), overridden.resync)
+	assert.Equal(t, 1, overridden.workers)
+
+	// Overrides do not affect the controller defaults
+	assert.Equal(t, time.Minute, ktr.resync)
+	assert.Equal(t, 3, ktr.workers)
+}
+
+func TestControllerOptions(t *testing.T) {
+	tests := []struct {
+		name string
+		opt  ControllerOption
+	}{
+		{"Logger", WithLogger(nil)},
+		{"Resync", WithResync(-time.Second)},
+		{"Workers", WithWorkers(0)},
+		{"RateLimiter", WithRateLimiter(nil)},
+	}
+
+	for _, tt := range tests {
+		_, err := NewController("test", fake.NewSimpleClientset(), tt.opt)
+		assert.Error(t, err, "Invalid option %s must fail.", tt.name)
+	}
+}
+
+func TestUnstructuredKind(t *testing.T) {
+	foo := &kind.Unstructured{Resource: schema.GroupVersionResource{Group: "example.com", Version: "v1", Resource: "foos"}, Kind: "Foo"}
+	obj := &unstructured.Unstructured{}
+	obj.SetAPIVersion("example.com/v1")
+	obj.SetKind("Foo")
+	obj.SetNamespace("default")
+	obj.SetName("a")
+	client := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), obj)
+	onCreate := OnCreate(func(*Kontext, metav1.Object) error { return nil })
+
+	ktr, err := NewController("test", fake.NewSimpleClientset(), WithCluster("eu", fake.NewSimpleClientset()))
+	require.NoError(t, err)
+	_, err = ktr.NewHandler(Kind(foo), onCreate)
+	assert.Error(t, err, "Unstructured kinds require a dynamic client")
+	_, err = NewController("test", fake.NewSimpleClientset(), WithDynamicClient(nil))
+	assert.Error(t, err)
+
+	ktr, err = NewController("test", fake.NewSimpleClientset(), WithDynamicClient(client), WithCluster("eu", fake.NewSimpleClientset()))
+	require.NoError(t, err)
+	_, err = ktr.NewHandler(Kind(foo), InCluster("eu"), onCreate)
+	assert.Error(t, err, "Unstructured kinds are not supported in named clusters")
+	handler, err := ktr.NewHandler(Kind(foo), OnNamespace("default"), onCreate)
+	require.NoError(t, err)
+	assert.Equal(t, "foo", handler.Name())
+
+	chanStop := make(chan struct{})
+	defer close(chanStop)
+	handler.startInformers(chanStop)
+	require.NoError(t, handler.waitForCacheSync(chanStop))
+	cached, err := handler.informer.Get("default", "a")
+	require.NoError(t, err)
+	objs, err := handler.informer.List()
+	require.NoError(t, err)
+	assert.Len(t, objs, 1)
+
+	ktx := handler.newEventContext(context.Background(), &updateEvent{baseEvent: &baseEvent{key: "default/a"}})
+	updated, err := ktx.UpdateStatus(cached, func(obj metav1.Object) error {
+		return unstructured.SetNestedField(obj.(*unstructured.Unstructured).Object, "Ready", "status", "phase")
+	})
+	require.NoError(t, err)
+	phase, _, _ := unstructured.NestedString(updated.(*unstructured.Unstructured).Object, "status", "phase")
+	assert.Equal(t, "Ready", phase)
+
+	patched, err := ktx.MergePatch(updated, func(obj metav1.Object) error {
+		obj.SetLabels(map[string]string{"app": "a"})
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"app": "a"}, patched.GetLabels())
+
+	var verbs []string
+	for _, action := range client.Actions() {
+		if action.GetVerb() == "list" || action.GetVerb() == "watch" {
+			assert.Equal(t, "default", action.GetNamespace(), "Informers must only watch the handler namespace")
+			continue
+		}
+		verbs = append(verbs, action.GetVerb()+"/"+action.GetSubresource())
+	}
+	assert.Equal(t, []string{"update/status", "patch/"}, verbs)
+}
+
+func TestDeleteTombstone(t *testing.T) {
+	ktr, err := NewController("test", fake.NewSimpleClientset())
+	require.NoError(t, err)
+
+	source := &watchedKind{informer: &watchedInformer{}}
+	handler, err := ktr.NewHandler(Kind(source), OnDelete(func(*Kontext, metav1.Object) error { return nil }))
+	require.NoError(t, err)
+	handler.setQueue(workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter()))
+
+	deleted := &extensionsv1beta1.Ingress{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "a"}}
+	source.informer.handler.OnDelete(cache.DeletedFinalStateUnknown{Key: "default/a", Obj: deleted})
+	require.Equal(t, 1, handler.queue.Len(), "Deleted objects must be recovered from tombstones")
+	event, _ := handler.queue.Get()
+	require.IsType(t, &deleteEvent{}, event)
+	assert.Equal(t, deleted, event.(*deleteEvent).obj)
+	handler.queue.Done(event)
+
+	source.informer.handler.OnDelete(cache.DeletedFinalStateUnknown{Key: "default/b"})
+	assert.Zero(t, handler.queue.Len(), "Invalid tombstones must be ignored")
+}
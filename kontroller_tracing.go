@@ -0,0 +1,102 @@
+package kolibri
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/xerrors"
+)
+
+// tracerName is the instrumentation name of the kolibri tracer.
+const tracerName = "github.com/radiofrance/kolibri"
+
+// WithTracing traces the events handling through the given OpenTelemetry
+// tracer provider. Each event becomes a span, from its enqueuing until it is
+// handled or dropped, with a child span for each attempt and, below it, for
+// each handler function call. The span of the current call is available
+// through the Kontext (see Kontext.Span), so the API calls of the handler
+// functions join the trace.
+func WithTracing(provider trace.TracerProvider) controllerOption {
+	return func(k *Kontroller) error {
+		if provider == nil {
+			return xerrors.Errorf("tracer provider cannot be nil")
+		}
+		k.tracerProvider = provider
+		return nil
+	}
+}
+
+// tracer returns the controller tracer, which records nothing without
+// tracing.
+func (k *Kontroller) tracer() trace.Tracer {
+	if k.tracerProvider == nil {
+		return trace.NewNoopTracerProvider().Tracer(tracerName)
+	}
+	return k.tracerProvider.Tracer(tracerName)
+}
+
+// startEventSpan starts the span of the given event, if not already started.
+func (h *Handler) startEventSpan(container eventContainer) {
+	if container.span() != nil {
+		return
+	}
+
+	_, span := h.ktr.tracer().Start(context.Background(), fmt.Sprintf("%s %s", h.name, eventType(container)),
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithTimestamp(container.addedAt()),
+		trace.WithAttributes(
+			attribute.String("kolibri.controller", h.ktr.name),
+			attribute.String("kolibri.handler", h.name),
+			attribute.String("kolibri.kind", h.kind.Name()),
+			attribute.String("kolibri.key", h.ktr.clusterKey(container.Key())),
+			attribute.String("kolibri.event", string(eventType(container))),
+		),
+	)
+	container.setSpan(span)
+}
+
+// startAttemptSpan starts the span of an attempt to handle the given event,
+// as a child of the event span.
+func (h *Handler) startAttemptSpan(ctx context.Context, container eventContainer, attempt int) (context.Context, trace.Span) {
+	if span := container.span(); span != nil {
+		ctx = trace.ContextWithSpan(ctx, span)
+	}
+	return h.ktr.tracer().Start(ctx, "attempt", trace.WithAttributes(
+		attribute.String("kolibri.kind", h.kind.Name()),
+		attribute.String("kolibri.key", h.ktr.clusterKey(container.Key())),
+		attribute.String("kolibri.event", string(eventType(container))),
+		attribute.Int("kolibri.attempt", attempt),
+	))
+}
+
+// startCallSpan starts the span of an handler function call, as a child of
+// the current attempt span.
+func (h *Handler) startCallSpan(ctx context.Context, container eventContainer, call int) (context.Context, trace.Span) {
+	return h.ktr.tracer().Start(ctx, "call", trace.WithAttributes(
+		attribute.String("kolibri.key", h.ktr.clusterKey(container.Key())),
+		attribute.String("kolibri.event", string(eventType(container))),
+		attribute.Int("kolibri.call", call),
+	))
+}
+
+// endSpan ends the given span, recording the given error if any.
+func endSpan(span trace.Span, err error) {
+	if span == nil {
+		return
+	}
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// endEventSpan ends the span of the given event, recording the reason why it
+// was not handled if any.
+func endEventSpan(container eventContainer, err error) {
+	endSpan(container.span(), err)
+	container.setSpan(nil)
+}
@@ -0,0 +1,87 @@
+package kolibri
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/xerrors"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// spanAttribute returns the value of the given span attribute.
+func spanAttribute(span sdktrace.ReadOnlySpan, key attribute.Key) attribute.Value {
+	for _, attr := range span.Attributes() {
+		if attr.Key == key {
+			return attr.Value
+		}
+	}
+	return attribute.Value{}
+}
+
+func TestTracing(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	handler := newFakeHandler(&corev1.Service{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "a"}})
+	require.NoError(t, WithTracing(provider)(handler.ktr))
+	assert.Error(t, WithTracing(nil)(handler.ktr))
+
+	var calls []trace.SpanContext
+	handler.events.updateFuncs = append(handler.events.updateFuncs, func(ktx *Kontext, _ metav1.Object) error {
+		calls = append(calls, ktx.Span().SpanContext())
+		if len(calls) == 1 {
+			return xerrors.New("failure")
+		}
+		return nil
+	})
+
+	handler.add(&updateEvent{baseEvent: &baseEvent{kind: "Service", key: "default/a"}})
+	assert.True(t, handler.processNextWorkItem(context.Background(), handler.queue))
+	assert.True(t, handler.processNextWorkItem(context.Background(), handler.queue))
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 5)
+	// call, attempt, call, attempt, event
+	event := spans[4]
+	assert.Equal(t, "service update", event.Name())
+	assert.Equal(t, "default/a", spanAttribute(event, "kolibri.key").AsString())
+	assert.Equal(t, "update", spanAttribute(event, "kolibri.event").AsString())
+	assert.Equal(t, codes.Unset, event.Status().Code)
+
+	for i, attempt := range []sdktrace.ReadOnlySpan{spans[1], spans[3]} {
+		assert.Equal(t, "attempt", attempt.Name())
+		assert.Equal(t, event.SpanContext().SpanID(), attempt.Parent().SpanID())
+		assert.Equal(t, int64(i+1), spanAttribute(attempt, "kolibri.attempt").AsInt64())
+	}
+	assert.Equal(t, codes.Error, spans[1].Status().Code)
+	assert.Equal(t, codes.Unset, spans[3].Status().Code)
+
+	for i, call := range []sdktrace.ReadOnlySpan{spans[0], spans[2]} {
+		assert.Equal(t, "call", call.Name())
+		assert.Equal(t, []sdktrace.ReadOnlySpan{spans[1], spans[3]}[i].SpanContext().SpanID(), call.Parent().SpanID())
+		assert.Equal(t, call.SpanContext(), calls[i], "Kontext must carry the call span")
+	}
+}
+
+func TestTracingDroppedEvent(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	handler := newFakeHandler()
+	require.NoError(t, WithTracing(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))(handler.ktr))
+
+	handler.add(&updateEvent{baseEvent: &baseEvent{kind: "Service", key: "default/a"}})
+	handler.drop("default/a")
+	assert.True(t, handler.processNextWorkItem(context.Background(), handler.queue))
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 1)
+	assert.Equal(t, codes.Error, spans[0].Status().Code)
+	assert.Equal(t, "event dropped by an operator", spans[0].Status().Description)
+}
@@ -39,8 +39,11 @@ func main() {
 	client, err := kubernetes.NewForConfig(config)
 	handleErr(err)
 
-	ktr := kolibri.NewController("service_watcher", client)
-	ktr.Logger = kzap.New(zap.NewExample())
+	ktr, err := kolibri.NewController("service_watcher", client,
+		kolibri.WithLogger(kzap.New(zap.NewExample())),
+		kolibri.WithWorkers(5),
+	)
+	handleErr(err)
 
 	svc, err := ktr.NewHandler(
 		kolibri.OnAllNamespaces(),